@@ -1,12 +1,263 @@
 package web
 
-import "net/http"
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	errBodyNil       = errors.New("web: body 为 nil")
+	errKeyNotFound   = errors.New("web: key 不存在")
+	errRedirectCode  = errors.New("web: 重定向的状态码必须是 3xx")
+	errInvalidTarget = errors.New("web: 只能绑定到结构体指针")
+)
 
 type Context struct {
 	Req        *http.Request
 	Resp       http.ResponseWriter
 	PathParams map[string]string
+	// MatchedRoute 命中的路由，例如 /user/:id，没有命中的时候是空字符串
+	MatchedRoute string
 
+	// RespStatusCode 和 RespData 会在所有 middleware 执行完毕之后统一写回去
 	RespStatusCode int
 	RespData       []byte
+
+	// UserValues 在 middleware 和 handler 之间传递数据，例如 session
+	UserValues map[string]any
+
+	// queryValues 缓存查询参数，避免每次都重新解析
+	queryValues url.Values
+
+	tplEngine TemplateEngine
+	// mi 命中的路由，为 nil 说明没有命中，命中的时候指向 matched
+	mi      *matchInfo
+	matched matchInfo
+	// allowedMethods 没有命中的时候，这个路径上注册了路由的 HTTP 方法
+	allowedMethods []string
+	// redirectTo 修正之后的路径，不为空说明需要重定向
+	redirectTo string
+}
+
+// reset 重置 Context，以便复用
+// RespData 不能复用底层数组，因为它可能指向 middleware 或者 handler 持有的数据
+func (c *Context) reset(writer http.ResponseWriter, request *http.Request, tplEngine TemplateEngine) {
+	c.Req = request
+	c.Resp = writer
+	c.PathParams = nil
+	c.MatchedRoute = ""
+	c.RespStatusCode = 0
+	c.RespData = nil
+	c.UserValues = nil
+	c.queryValues = nil
+	c.tplEngine = tplEngine
+	c.mi = nil
+	c.matched.reset()
+	c.allowedMethods = nil
+	c.redirectTo = ""
+}
+
+// Render 使用模板引擎渲染页面，渲染失败的时候响应码是 500
+func (c *Context) Render(tplName string, data any) error {
+	if c.tplEngine == nil {
+		c.RespStatusCode = http.StatusInternalServerError
+		return errTemplateEngineNil
+	}
+	var err error
+	c.RespData, err = c.tplEngine.Render(c.Req.Context(), tplName, data)
+	if err != nil {
+		c.RespStatusCode = http.StatusInternalServerError
+		return err
+	}
+	c.Resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.RespStatusCode = http.StatusOK
+	return nil
+}
+
+// BindJSON 解析 JSON 格式的请求体，并且按照 validate 标签校验
+// 校验失败返回 *ValidationError
+func (c *Context) BindJSON(val any) error {
+	if c.Req.Body == nil {
+		return errBodyNil
+	}
+	decoder := json.NewDecoder(c.Req.Body)
+	if err := decoder.Decode(val); err != nil {
+		return err
+	}
+	return validate(val, "json")
+}
+
+// BindXML 解析 XML 格式的请求体，并且按照 validate 标签校验
+func (c *Context) BindXML(val any) error {
+	if c.Req.Body == nil {
+		return errBodyNil
+	}
+	decoder := xml.NewDecoder(c.Req.Body)
+	if err := decoder.Decode(val); err != nil {
+		return err
+	}
+	return validate(val, "xml")
+}
+
+// BindForm 把表单（包括查询参数）绑定到结构体上
+// 字段名字取 form 标签，没有的话就用字段名
+func (c *Context) BindForm(val any) error {
+	if err := c.Req.ParseForm(); err != nil {
+		return err
+	}
+	if err := bindValues(c.Req.Form, val, "form"); err != nil {
+		return err
+	}
+	return validate(val, "form")
+}
+
+// FormValue 读取表单参数，包括查询参数
+func (c *Context) FormValue(key string) StringValue {
+	if err := c.Req.ParseForm(); err != nil {
+		return StringValue{err: err}
+	}
+	vals, ok := c.Req.Form[key]
+	if !ok || len(vals) == 0 {
+		return StringValue{err: fmt.Errorf("%w, key: %s", errKeyNotFound, key)}
+	}
+	return StringValue{val: vals[0]}
+}
+
+// QueryValue 读取查询参数
+func (c *Context) QueryValue(key string) StringValue {
+	if c.queryValues == nil {
+		c.queryValues = c.Req.URL.Query()
+	}
+	vals, ok := c.queryValues[key]
+	if !ok || len(vals) == 0 {
+		return StringValue{err: fmt.Errorf("%w, key: %s", errKeyNotFound, key)}
+	}
+	return StringValue{val: vals[0]}
+}
+
+// PathValue 读取路径参数
+func (c *Context) PathValue(key string) StringValue {
+	val, ok := c.PathParams[key]
+	if !ok {
+		return StringValue{err: fmt.Errorf("%w, key: %s", errKeyNotFound, key)}
+	}
+	return StringValue{val: val}
+}
+
+// RespJSON 以 JSON 格式输出响应
+func (c *Context) RespJSON(code int, val any) error {
+	bs, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	c.Resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+	c.RespStatusCode = code
+	c.RespData = bs
+	return nil
+}
+
+// RespXML 以 XML 格式输出响应
+func (c *Context) RespXML(code int, val any) error {
+	bs, err := xml.Marshal(val)
+	if err != nil {
+		return err
+	}
+	c.Resp.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	c.RespStatusCode = code
+	c.RespData = bs
+	return nil
+}
+
+// RespString 输出纯文本响应
+func (c *Context) RespString(code int, s string) error {
+	c.Resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	c.RespStatusCode = code
+	c.RespData = []byte(s)
+	return nil
+}
+
+// Redirect 重定向到 location，code 必须是 3xx
+func (c *Context) Redirect(code int, location string) error {
+	if code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect {
+		return fmt.Errorf("%w, code: %d", errRedirectCode, code)
+	}
+	c.Resp.Header().Set("Location", location)
+	c.RespStatusCode = code
+	return nil
+}
+
+// RedirectFound 使用 302 重定向
+func (c *Context) RedirectFound(location string) error {
+	return c.Redirect(http.StatusFound, location)
+}
+
+// RedirectPermanent 使用 301 重定向
+func (c *Context) RedirectPermanent(location string) error {
+	return c.Redirect(http.StatusMovedPermanently, location)
+}
+
+// StringValue 用于支持链式调用，例如 ctx.QueryValue("id").ToInt64()
+type StringValue struct {
+	val string
+	err error
+}
+
+func (s StringValue) String() (string, error) {
+	return s.val, s.err
+}
+
+func (s StringValue) ToInt64() (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.ParseInt(s.val, 10, 64)
+}
+
+func (s StringValue) ToInt() (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.Atoi(s.val)
+}
+
+func (s StringValue) ToUint64() (uint64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.ParseUint(s.val, 10, 64)
+}
+
+func (s StringValue) ToBool() (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	return strconv.ParseBool(s.val)
+}
+
+func (s StringValue) ToFloat64() (float64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return strconv.ParseFloat(s.val, 64)
+}
+
+// ToTime 按照 layout 解析时间，例如 time.RFC3339
+func (s StringValue) ToTime(layout string) (time.Time, error) {
+	if s.err != nil {
+		return time.Time{}, s.err
+	}
+	return time.Parse(layout, s.val)
+}
+
+func (s StringValue) ToDuration() (time.Duration, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return time.ParseDuration(s.val)
 }
@@ -0,0 +1,249 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindUser struct {
+	Name     string    `json:"name" xml:"name" form:"name" validate:"required,max=5"`
+	Age      int       `json:"age" xml:"age" form:"age" validate:"min=1,max=150"`
+	Gender   string    `json:"gender" xml:"gender" form:"gender" validate:"oneof=male female"`
+	Tags     []string  `json:"tags" xml:"tags" form:"tags"`
+	Birthday time.Time `json:"birthday" xml:"birthday" form:"birthday"`
+}
+
+func TestContext_BindJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		wantVal  bindUser
+		wantErr  error
+		checkErr func(t *testing.T, err error)
+	}{
+		{
+			name:    "success",
+			body:    `{"name":"Tom","age":18,"gender":"male"}`,
+			wantVal: bindUser{Name: "Tom", Age: 18, Gender: "male"},
+		},
+		{
+			name: "validation failed",
+			body: `{"name":"TomJerry","age":0,"gender":"unknown"}`,
+			wantErr: &ValidationError{Fields: []FieldError{
+				{Field: "name", Rule: "max", Message: "不能大于 5"},
+				{Field: "age", Rule: "min", Message: "不能小于 1"},
+				{Field: "gender", Rule: "oneof", Message: "必须是 [male female] 其中之一"},
+			}},
+		},
+		{
+			name: "required",
+			body: `{"age":18,"gender":"female"}`,
+			wantErr: &ValidationError{Fields: []FieldError{
+				{Field: "name", Rule: "required", Message: "不能为空"},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			ctx := &Context{Req: req}
+			var u bindUser
+			err := ctx.BindJSON(&u)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, u)
+		})
+	}
+}
+
+type badRuleUser struct {
+	Name string `json:"name" validate:"required,maxlen=5"`
+}
+
+type badLimitUser struct {
+	Age int `json:"age" validate:"min=abc"`
+}
+
+// 标签写错了返回普通的 error，不会 panic
+func TestContext_BindJSON_BadTag(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"Tom"}`))
+		ctx := &Context{Req: req}
+		err := ctx.BindJSON(&badRuleUser{})
+		assert.EqualError(t, err, "web: 不支持的校验规则 maxlen=5, 字段 badRuleUser.Name")
+
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"age":1}`))
+		ctx = &Context{Req: req}
+		err = ctx.BindJSON(&badLimitUser{})
+		assert.EqualError(t, err, "web: 非法的校验规则 min=abc, 字段 badLimitUser.Age")
+	}
+}
+
+func TestContext_BindXML(t *testing.T) {
+	body := `<bindUser><name>Tom</name><age>18</age><gender>male</gender></bindUser>`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	ctx := &Context{Req: req}
+	var u bindUser
+	require.NoError(t, ctx.BindXML(&u))
+	assert.Equal(t, bindUser{Name: "Tom", Age: 18, Gender: "male"}, u)
+}
+
+func TestContext_BindForm(t *testing.T) {
+	form := url.Values{}
+	form.Set("name", "Tom")
+	form.Set("age", "18")
+	form.Set("gender", "female")
+	form.Add("tags", "a")
+	form.Add("tags", "b")
+	form.Set("birthday", "2000-01-02T00:00:00Z")
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := &Context{Req: req}
+	var u bindUser
+	require.NoError(t, ctx.BindForm(&u))
+	assert.Equal(t, bindUser{
+		Name:     "Tom",
+		Age:      18,
+		Gender:   "female",
+		Tags:     []string{"a", "b"},
+		Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+	}, u)
+
+	req = httptest.NewRequest(http.MethodPost, "/?age=abc", nil)
+	ctx = &Context{Req: req}
+	assert.Error(t, ctx.BindForm(&u))
+}
+
+func TestContext_Value(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?id=12&ok=true&t=2000-01-02T00:00:00Z&u=-1", nil)
+	ctx := &Context{Req: req, PathParams: map[string]string{"id": "34"}}
+
+	id, err := ctx.QueryValue("id").ToInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), id)
+
+	ok, err := ctx.QueryValue("ok").ToBool()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	tm, err := ctx.QueryValue("t").ToTime(time.RFC3339)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC), tm)
+
+	_, err = ctx.QueryValue("u").ToUint64()
+	assert.Error(t, err)
+
+	_, err = ctx.QueryValue("not exist").String()
+	assert.ErrorIs(t, err, errKeyNotFound)
+
+	pid, err := ctx.PathValue("id").ToUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(34), pid)
+
+	_, err = ctx.PathValue("name").String()
+	assert.ErrorIs(t, err, errKeyNotFound)
+}
+
+func TestContext_Resp(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/json", func(ctx *Context) {
+		_ = ctx.RespJSON(http.StatusOK, map[string]string{"name": "Tom"})
+	})
+	s.Get("/xml", func(ctx *Context) {
+		_ = ctx.RespXML(http.StatusOK, bindUser{Name: "Tom"})
+	})
+	s.Get("/string", func(ctx *Context) {
+		_ = ctx.RespString(http.StatusCreated, "hello")
+	})
+	s.Get("/redirect", func(ctx *Context) {
+		_ = ctx.RedirectFound("/string")
+	})
+	s.Post("/user", func(ctx *Context) {
+		var u bindUser
+		if err := ctx.BindJSON(&u); err != nil {
+			_ = ctx.RespJSON(http.StatusBadRequest, err)
+			return
+		}
+		_ = ctx.RespJSON(http.StatusOK, u)
+	})
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantCode   int
+		wantHeader http.Header
+		wantBody   string
+	}{
+		{
+			name:       "json",
+			method:     http.MethodGet,
+			path:       "/json",
+			wantCode:   http.StatusOK,
+			wantHeader: http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+			wantBody:   `{"name":"Tom"}`,
+		},
+		{
+			name:       "xml",
+			method:     http.MethodGet,
+			path:       "/xml",
+			wantCode:   http.StatusOK,
+			wantHeader: http.Header{"Content-Type": {"application/xml; charset=utf-8"}},
+			wantBody:   `<bindUser><name>Tom</name><age>0</age><gender></gender><birthday>0001-01-01T00:00:00Z</birthday></bindUser>`,
+		},
+		{
+			name:       "string",
+			method:     http.MethodGet,
+			path:       "/string",
+			wantCode:   http.StatusCreated,
+			wantHeader: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			wantBody:   "hello",
+		},
+		{
+			name:       "redirect",
+			method:     http.MethodGet,
+			path:       "/redirect",
+			wantCode:   http.StatusFound,
+			wantHeader: http.Header{"Location": {"/string"}},
+		},
+		{
+			name:       "bad request",
+			method:     http.MethodPost,
+			path:       "/user",
+			body:       `{"age":18,"gender":"male"}`,
+			wantCode:   http.StatusBadRequest,
+			wantHeader: http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+			wantBody:   `{"fields":[{"field":"name","rule":"required","message":"不能为空"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			for k := range tc.wantHeader {
+				assert.Equal(t, tc.wantHeader.Get(k), recorder.Header().Get(k))
+			}
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestContext_Redirect(t *testing.T) {
+	ctx := &Context{Resp: httptest.NewRecorder()}
+	assert.ErrorIs(t, ctx.Redirect(http.StatusOK, "/"), errRedirectCode)
+	assert.NoError(t, ctx.RedirectPermanent("/home"))
+	assert.Equal(t, http.StatusMovedPermanently, ctx.RespStatusCode)
+}
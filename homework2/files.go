@@ -0,0 +1,369 @@
+package web
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileUploader 处理 multipart 表单上传
+type FileUploader struct {
+	// FileField 文件在表单里面的字段名字
+	FileField string
+	// DstPathFunc 计算目标路径
+	DstPathFunc func(fh *multipart.FileHeader) string
+	// MaxSize 请求体的最大字节数，超过了返回 413，为 0 则不限制
+	MaxSize int64
+	// MaxMemory 解析表单的时候最多使用的内存，超过的部分会写到临时文件，默认 32MB
+	MaxMemory int64
+}
+
+func (f *FileUploader) Handle() HandleFunc {
+	maxMemory := f.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = 32 << 20
+	}
+	return func(ctx *Context) {
+		var limited *limitedBody
+		if f.MaxSize > 0 {
+			limited = &limitedBody{ReadCloser: ctx.Req.Body, remaining: f.MaxSize}
+			ctx.Req.Body = limited
+		}
+		if err := ctx.Req.ParseMultipartForm(maxMemory); err != nil {
+			if limited != nil && limited.exceeded {
+				ctx.RespStatusCode = http.StatusRequestEntityTooLarge
+				ctx.RespData = []byte("文件过大")
+				return
+			}
+			ctx.RespStatusCode = http.StatusBadRequest
+			ctx.RespData = []byte("上传失败")
+			return
+		}
+		src, fh, err := ctx.Req.FormFile(f.FileField)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusBadRequest
+			ctx.RespData = []byte("上传失败，未找到文件")
+			return
+		}
+		defer src.Close()
+		dst, err := os.OpenFile(f.DstPathFunc(fh), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+		if err != nil {
+			log.Println("web: 打开目标文件失败", err)
+			ctx.RespStatusCode = http.StatusInternalServerError
+			ctx.RespData = []byte("上传失败")
+			return
+		}
+		defer dst.Close()
+		if _, err = io.CopyBuffer(dst, src, nil); err != nil {
+			log.Println("web: 保存文件失败", err)
+			ctx.RespStatusCode = http.StatusInternalServerError
+			ctx.RespData = []byte("上传失败")
+			return
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("上传成功")
+	}
+}
+
+var errBodyTooLarge = errors.New("web: 请求体过大")
+
+// limitedBody 限制读取的字节数
+// 解析表单的时候错误会被包装，所以用 exceeded 来判断是不是超过了限制
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// 多读一个字节，判断是不是恰好读完
+		var b [1]byte
+		n, _ := l.ReadCloser.Read(b[:])
+		if n > 0 {
+			l.exceeded = true
+			return 0, errBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// FileDownloader 从 Dir 里面下载文件，文件名从查询参数 file 里面读取
+type FileDownloader struct {
+	Dir string
+}
+
+func (f *FileDownloader) Handle() HandleFunc {
+	return func(ctx *Context) {
+		req, err := ctx.QueryValue("file").String()
+		if err != nil || req == "" {
+			ctx.RespStatusCode = http.StatusBadRequest
+			ctx.RespData = []byte("找不到目标文件")
+			return
+		}
+		// 用 / 开头再 Clean，就不可能通过 .. 跳出 Dir
+		dst := filepath.Join(f.Dir, filepath.FromSlash(path.Clean("/"+req)))
+		file, err := os.Open(dst)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusNotFound
+			ctx.RespData = []byte("找不到目标文件")
+			return
+		}
+		defer file.Close()
+		stat, err := file.Stat()
+		if err != nil || stat.IsDir() {
+			ctx.RespStatusCode = http.StatusNotFound
+			ctx.RespData = []byte("找不到目标文件")
+			return
+		}
+		header := ctx.Resp.Header()
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment",
+			map[string]string{"filename": stat.Name()}))
+		header.Set("Content-Type", "application/octet-stream")
+		header.Set("Content-Transfer-Encoding", "binary")
+		serveContent(ctx, stat.Name(), stat.ModTime(), file)
+	}
+}
+
+// serveContent 用 http.ServeContent 处理 Range 和条件请求，
+// 但是响应码和响应体写到 RespStatusCode 和 RespData 里面，和其它 handler 一样由 flushResp 写回去，
+// 这样 middleware 能拿到 304、206 之类的响应码，按照响应码注册的处理函数也能生效
+func serveContent(ctx *Context, name string, modTime time.Time, content io.ReadSeeker) {
+	http.ServeContent(respBuffer{ctx: ctx}, ctx.Req, name, modTime, content)
+}
+
+// respBuffer 把响应写到 Context 里面的 http.ResponseWriter，header 还是直接设置在 ctx.Resp 上
+type respBuffer struct {
+	ctx *Context
+}
+
+func (b respBuffer) Header() http.Header {
+	return b.ctx.Resp.Header()
+}
+
+func (b respBuffer) WriteHeader(statusCode int) {
+	b.ctx.RespStatusCode = statusCode
+}
+
+func (b respBuffer) Write(data []byte) (int, error) {
+	if b.ctx.RespStatusCode == 0 {
+		b.ctx.RespStatusCode = http.StatusOK
+	}
+	b.ctx.RespData = append(b.ctx.RespData, data...)
+	return len(data), nil
+}
+
+type StaticResourceHandlerOption func(handler *StaticResourceHandler)
+
+// StaticResourceHandler 把通配符路由映射到一个目录或者 fs.FS 上
+// 例如注册 /static/*，那么 /static/js/index.js 会读取 js/index.js
+// Content-Type、Range、If-Modified-Since 和 If-None-Match 都交给 http.ServeContent 处理
+type StaticResourceHandler struct {
+	fsys   fs.FS
+	prefix string
+	// cache 缓存小文件，为 nil 说明不缓存
+	cache *fileCache
+	// maxFileSize 超过这个大小的文件不会被缓存
+	maxFileSize int64
+}
+
+// NewStaticResourceHandler 创建静态资源处理器
+// prefix 是注册的路由前缀，例如 /static，请求路径去掉 prefix 之后就是文件路径
+func NewStaticResourceHandler(fsys fs.FS, prefix string, opts ...StaticResourceHandlerOption) *StaticResourceHandler {
+	res := &StaticResourceHandler{
+		fsys:   fsys,
+		prefix: strings.TrimSuffix(prefix, "/"),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// NewStaticResourceHandlerFromDir 从目录中读取静态资源
+func NewStaticResourceHandlerFromDir(dir string, prefix string, opts ...StaticResourceHandlerOption) *StaticResourceHandler {
+	return NewStaticResourceHandler(os.DirFS(dir), prefix, opts...)
+}
+
+// StaticWithCache 缓存不超过 maxFileSize 的文件，最多缓存 maxCnt 个，按照 LRU 淘汰
+func StaticWithCache(maxFileSize int64, maxCnt int) StaticResourceHandlerOption {
+	return func(handler *StaticResourceHandler) {
+		handler.maxFileSize = maxFileSize
+		handler.cache = newFileCache(maxCnt)
+	}
+}
+
+func (h *StaticResourceHandler) Handle(ctx *Context) {
+	name := strings.TrimPrefix(ctx.Req.URL.Path, h.prefix)
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	if h.cache != nil {
+		if itm, ok := h.cache.get(name); ok {
+			// 文件可能被修改或者删除了，修改时间和大小都没变才使用缓存
+			stat, err := fs.Stat(h.fsys, name)
+			if err == nil && stat.ModTime().Equal(itm.modTime) && stat.Size() == itm.size {
+				h.serve(ctx, itm.name, itm.modTime, itm.etag, bytes.NewReader(itm.data))
+				return
+			}
+			h.cache.remove(name)
+		}
+	}
+
+	file, err := h.fsys.Open(name)
+	if err != nil {
+		ctx.RespStatusCode = http.StatusNotFound
+		ctx.RespData = []byte("Not Found")
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		ctx.RespStatusCode = http.StatusNotFound
+		ctx.RespData = []byte("Not Found")
+		return
+	}
+
+	if h.cache != nil && stat.Size() <= h.maxFileSize {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			return
+		}
+		itm := &fileItem{name: stat.Name(), modTime: stat.ModTime(), size: stat.Size(),
+			etag: contentETag(data), data: data}
+		h.cache.put(name, itm)
+		h.serve(ctx, itm.name, itm.modTime, itm.etag, bytes.NewReader(data))
+		return
+	}
+
+	rs, ok := file.(io.ReadSeeker)
+	if !ok {
+		// 有些 fs.FS 的实现不支持 Seek，只能全部读出来
+		data, err := io.ReadAll(file)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			return
+		}
+		rs = bytes.NewReader(data)
+	}
+	etag := fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size())
+	if stat.ModTime().IsZero() {
+		// embed.FS 之类的没有修改时间，只用大小的话，内容变了大小没变，客户端会拿到旧的内容
+		hash := sha256.New()
+		if _, err = io.Copy(hash, rs); err == nil {
+			_, err = rs.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			ctx.RespStatusCode = http.StatusInternalServerError
+			return
+		}
+		etag = hashETag(hash)
+	}
+	h.serve(ctx, stat.Name(), stat.ModTime(), etag, rs)
+}
+
+func (h *StaticResourceHandler) serve(ctx *Context, name string, modTime time.Time, etag string, content io.ReadSeeker) {
+	ctx.Resp.Header().Set("ETag", etag)
+	serveContent(ctx, name, modTime, content)
+}
+
+// contentETag 用内容的哈希值作为 ETag
+func contentETag(data []byte) string {
+	hash := sha256.New()
+	hash.Write(data)
+	return hashETag(hash)
+}
+
+func hashETag(h hash.Hash) string {
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+type fileItem struct {
+	name    string
+	modTime time.Time
+	size    int64
+	etag    string
+	data    []byte
+}
+
+// fileCache 一个简单的 LRU 缓存
+type fileCache struct {
+	mutex  sync.Mutex
+	maxCnt int
+	ll     *list.List
+	items  map[string]*list.Element
+}
+
+type fileCacheEntry struct {
+	key string
+	itm *fileItem
+}
+
+func newFileCache(maxCnt int) *fileCache {
+	return &fileCache{
+		maxCnt: maxCnt,
+		ll:     list.New(),
+		items:  make(map[string]*list.Element, maxCnt),
+	}
+}
+
+func (c *fileCache) get(key string) (*fileItem, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ele, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(ele)
+	return ele.Value.(*fileCacheEntry).itm, true
+}
+
+func (c *fileCache) remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if ele, ok := c.items[key]; ok {
+		c.ll.Remove(ele)
+		delete(c.items, key)
+	}
+}
+
+func (c *fileCache) put(key string, itm *fileItem) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if ele, ok := c.items[key]; ok {
+		ele.Value.(*fileCacheEntry).itm = itm
+		c.ll.MoveToFront(ele)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&fileCacheEntry{key: key, itm: itm})
+	for c.maxCnt > 0 && c.ll.Len() > c.maxCnt {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*fileCacheEntry).key)
+	}
+}
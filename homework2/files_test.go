@@ -0,0 +1,268 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileUploader_Handle(t *testing.T) {
+	dir := t.TempDir()
+	s := NewHTTPServer()
+	s.Post("/upload", (&FileUploader{
+		FileField: "myfile",
+		DstPathFunc: func(fh *multipart.FileHeader) string {
+			return filepath.Join(dir, fh.Filename)
+		},
+		MaxSize: 1024,
+	}).Handle())
+
+	newReq := func(t *testing.T, field string, content []byte) *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		fw, err := writer.CreateFormFile(field, "hello.txt")
+		require.NoError(t, err)
+		_, err = fw.Write(content)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	testCases := []struct {
+		name     string
+		req      *http.Request
+		wantCode int
+		wantFile string
+	}{
+		{
+			name:     "success",
+			req:      newReq(t, "myfile", []byte("hello, world")),
+			wantCode: http.StatusOK,
+			wantFile: "hello, world",
+		},
+		{
+			name:     "no file",
+			req:      newReq(t, "other", []byte("hello, world")),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "too large",
+			req:      newReq(t, "myfile", bytes.Repeat([]byte("a"), 2048)),
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_ = os.Remove(filepath.Join(dir, "hello.txt"))
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, tc.req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantFile == "" {
+				return
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "hello.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFile, string(data))
+		})
+	}
+}
+
+func TestFileDownloader_Handle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello, world"), 0644))
+	s := NewHTTPServer()
+	s.Get("/download", (&FileDownloader{Dir: dir}).Handle())
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "success",
+			path:     "/download?file=hello.txt",
+			wantCode: http.StatusOK,
+			wantBody: "hello, world",
+		},
+		{
+			name:     "not found",
+			path:     "/download?file=abc.txt",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "escape dir",
+			path:     "/download?file=../../etc/passwd",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "no file",
+			path:     "/download",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, `attachment; filename=hello.txt`, recorder.Header().Get("Content-Disposition"))
+		})
+	}
+}
+
+func TestStaticResourceHandler_Handle(t *testing.T) {
+	modTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"js/index.js":  {Data: []byte("console.log('hello')"), ModTime: modTime},
+		"index.html":   {Data: []byte("<html></html>"), ModTime: modTime},
+		"big/file.txt": {Data: []byte("0123456789abcdef"), ModTime: modTime},
+	}
+	for _, withCache := range []bool{false, true} {
+		var opts []StaticResourceHandlerOption
+		if withCache {
+			opts = append(opts, StaticWithCache(16, 2))
+		}
+		h := NewStaticResourceHandler(fsys, "/static", opts...)
+		s := NewHTTPServer()
+		s.Get("/static/*", h.Handle)
+		// 缓存的文件用内容的哈希值作为 ETag
+		indexETag := fmt.Sprintf(`"%x-%x"`, modTime.UnixNano(), 13)
+		if withCache {
+			indexETag = contentETag([]byte("<html></html>"))
+		}
+
+		testCases := []struct {
+			name        string
+			path        string
+			header      http.Header
+			wantCode    int
+			wantBody    string
+			wantCntType string
+		}{
+			{
+				name:        "js",
+				path:        "/static/js/index.js",
+				wantCode:    http.StatusOK,
+				wantBody:    "console.log('hello')",
+				wantCntType: "text/javascript; charset=utf-8",
+			},
+			{
+				name:        "html",
+				path:        "/static/index.html",
+				wantCode:    http.StatusOK,
+				wantBody:    "<html></html>",
+				wantCntType: "text/html; charset=utf-8",
+			},
+			{
+				name:     "range",
+				path:     "/static/big/file.txt",
+				header:   http.Header{"Range": {"bytes=2-5"}},
+				wantCode: http.StatusPartialContent,
+				wantBody: "2345",
+			},
+			{
+				name:     "if none match",
+				path:     "/static/index.html",
+				header:   http.Header{"If-None-Match": {indexETag}},
+				wantCode: http.StatusNotModified,
+			},
+			{
+				name:     "if modified since",
+				path:     "/static/index.html",
+				header:   http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}},
+				wantCode: http.StatusNotModified,
+			},
+			{
+				name:     "not found",
+				path:     "/static/abc.js",
+				wantCode: http.StatusNotFound,
+			},
+			{
+				name:     "dir",
+				path:     "/static/js",
+				wantCode: http.StatusNotFound,
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				// 执行两次，第二次会命中缓存
+				for i := 0; i < 2; i++ {
+					req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+					for k, v := range tc.header {
+						req.Header[k] = v
+					}
+					recorder := httptest.NewRecorder()
+					s.ServeHTTP(recorder, req)
+					assert.Equal(t, tc.wantCode, recorder.Code)
+					if tc.wantBody != "" {
+						assert.Equal(t, tc.wantBody, recorder.Body.String())
+					}
+					if tc.wantCntType != "" {
+						assert.Equal(t, tc.wantCntType, recorder.Header().Get("Content-Type"))
+					}
+				}
+			})
+		}
+		if withCache {
+			// js/index.js 超过了大小限制，不会被缓存
+			_, ok := h.cache.get("js/index.js")
+			assert.False(t, ok)
+			assert.Equal(t, 2, h.cache.ll.Len())
+		}
+	}
+}
+
+func TestStaticResourceHandler_ETag(t *testing.T) {
+	for _, withCache := range []bool{false, true} {
+		var opts []StaticResourceHandlerOption
+		if withCache {
+			opts = append(opts, StaticWithCache(16, 2))
+		}
+		// 和 embed.FS 一样没有修改时间
+		fsys := fstest.MapFS{"app.js": {Data: []byte("v1")}}
+		h := NewStaticResourceHandler(fsys, "/static", opts...)
+		s := NewHTTPServer()
+		s.Get("/static/*", h.Handle)
+
+		get := func(etag string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			return recorder
+		}
+		resp := get("")
+		oldETag := resp.Header().Get("ETag")
+		assert.Equal(t, contentETag([]byte("v1")), oldETag)
+		assert.Equal(t, http.StatusNotModified, get(oldETag).Code)
+
+		// 内容变了，大小没变，ETag 也要变。有缓存的时候修改时间变了才会重新读取
+		fsys["app.js"] = &fstest.MapFile{Data: []byte("v2"), ModTime: time.Now()}
+		resp = get(oldETag)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "v2", resp.Body.String())
+
+		// 文件被删除之后缓存也失效
+		delete(fsys, "app.js")
+		assert.Equal(t, http.StatusNotFound, get("").Code)
+	}
+}
@@ -1,5 +1,6 @@
 package web
 
+// Middleware 函数式的责任链模式
 type Middleware func(next HandleFunc) HandleFunc
 
 type MiddlewareBuilder struct{}
@@ -8,10 +9,12 @@ func NewBuilder() *MiddlewareBuilder {
 	return &MiddlewareBuilder{}
 }
 
+// Build 返回一个在响应后面追加 s 的 middleware
+// 追加而不是覆盖，这样多个 middleware 组合的时候效果都能保留下来
 func (b *MiddlewareBuilder) Build(s string) Middleware {
 	return func(next HandleFunc) HandleFunc {
 		return func(ctx *Context) {
-			ctx.RespData = []byte(s)
+			ctx.RespData = append(ctx.RespData, s...)
 			next(ctx)
 		}
 	}
@@ -0,0 +1,56 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"log"
+	web "myhomework/homework2"
+	"time"
+)
+
+type MiddlewareBuilder struct {
+	logFunc func(accessLog string)
+}
+
+func NewBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		logFunc: func(accessLog string) {
+			log.Println(accessLog)
+		},
+	}
+}
+
+func (b *MiddlewareBuilder) LogFunc(fn func(accessLog string)) *MiddlewareBuilder {
+	b.logFunc = fn
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			start := time.Now()
+			// 要在 next 之后记录，才能拿到命中的路由和响应码
+			defer func() {
+				l := accessLog{
+					Host:       ctx.Req.Host,
+					Route:      ctx.MatchedRoute,
+					HTTPMethod: ctx.Req.Method,
+					Path:       ctx.Req.URL.Path,
+					StatusCode: ctx.RespStatusCode,
+					Duration:   time.Since(start).String(),
+				}
+				data, _ := json.Marshal(l)
+				b.logFunc(string(data))
+			}()
+			next(ctx)
+		}
+	}
+}
+
+type accessLog struct {
+	Host       string `json:"host,omitempty"`
+	Route      string `json:"route,omitempty"`
+	HTTPMethod string `json:"http_method,omitempty"`
+	Path       string `json:"path,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+}
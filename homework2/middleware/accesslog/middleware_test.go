@@ -0,0 +1,63 @@
+package accesslog
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	var logs []string
+	builder := NewBuilder().LogFunc(func(accessLog string) {
+		logs = append(logs, accessLog)
+	})
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	s.Get("/user/:id", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/user/123", nil))
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/order", nil))
+
+	assert.Len(t, logs, 2)
+	assert.Regexp(t, `^\{"host":"localhost","route":"/user/:id","http_method":"GET","path":"/user/123","status_code":200,"duration":".+"\}$`, logs[0])
+	assert.Regexp(t, `^\{"host":"localhost","http_method":"GET","path":"/order","status_code":404,"duration":".+"\}$`, logs[1])
+}
+
+// TestMiddlewareBuilder_StaticFile 静态文件的 304 和 206 也要记录真实的响应码
+func TestMiddlewareBuilder_StaticFile(t *testing.T) {
+	var logs []string
+	builder := NewBuilder().LogFunc(func(accessLog string) {
+		logs = append(logs, accessLog)
+	})
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	fsys := fstest.MapFS{"app.js": {Data: []byte("0123456789"), ModTime: time.Now()}}
+	s.Get("/static/*", web.NewStaticResourceHandler(fsys, "/static").Handle)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/static/app.js", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/static/app.js", nil)
+	req.Header.Set("If-None-Match", recorder.Header().Get("ETag"))
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/static/app.js", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusPartialContent, recorder.Code)
+	assert.Equal(t, "0123", recorder.Body.String())
+
+	assert.Len(t, logs, 3)
+	assert.Contains(t, logs[0], `"status_code":200`)
+	assert.Contains(t, logs[1], `"status_code":304`)
+	assert.Contains(t, logs[2], `"status_code":206`)
+}
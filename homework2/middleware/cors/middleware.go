@@ -0,0 +1,101 @@
+package cors
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MiddlewareBuilder 处理跨域请求
+// 预检请求会在这里直接返回，所以应该注册为全局 middleware
+type MiddlewareBuilder struct {
+	// AllowOrigins 允许的来源，* 表示允许所有
+	// AllowCredentials 为 true 的时候不能使用 *，必须明确列出来源
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	// MaxAge 预检请求的缓存时间，单位秒，0 表示不设置
+	MaxAge int
+}
+
+func NewBuilder(origins ...string) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		AllowOrigins: origins,
+		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodHead},
+		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization"},
+	}
+}
+
+// Build 允许携带凭证的同时允许所有来源的话会 panic，
+// 因为这样任何网站都能带着用户的 cookie 读取响应
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	if b.AllowCredentials {
+		for _, o := range b.AllowOrigins {
+			if o == "*" {
+				panic("cors: 允许携带凭证的时候不能使用 *，必须明确列出允许的来源")
+			}
+		}
+	}
+	methods := strings.Join(b.AllowMethods, ", ")
+	headers := strings.Join(b.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(b.ExposeHeaders, ", ")
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			origin := ctx.Req.Header.Get("Origin")
+			// 不是跨域请求
+			if origin == "" {
+				next(ctx)
+				return
+			}
+			header := ctx.Resp.Header()
+			header.Add("Vary", "Origin")
+			allowed, ok := b.allowOrigin(origin)
+			if !ok {
+				if ctx.Req.Method == http.MethodOptions {
+					ctx.RespStatusCode = http.StatusForbidden
+					return
+				}
+				next(ctx)
+				return
+			}
+			header.Set("Access-Control-Allow-Origin", allowed)
+			if b.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			// 预检请求
+			if ctx.Req.Method == http.MethodOptions &&
+				ctx.Req.Header.Get("Access-Control-Request-Method") != "" {
+				header.Set("Access-Control-Allow-Methods", methods)
+				if headers != "" {
+					header.Set("Access-Control-Allow-Headers", headers)
+				}
+				if b.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", strconv.Itoa(b.MaxAge))
+				}
+				ctx.RespStatusCode = http.StatusNoContent
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// allowOrigin 返回 Access-Control-Allow-Origin 的值
+func (b *MiddlewareBuilder) allowOrigin(origin string) (string, bool) {
+	for _, o := range b.AllowOrigins {
+		if o == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(o, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,114 @@
+package cors
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	builder := NewBuilder("http://a.com")
+	builder.MaxAge = 600
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	s.Get("/user", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+
+	testCases := []struct {
+		name       string
+		method     string
+		header     http.Header
+		wantCode   int
+		wantHeader http.Header
+	}{
+		{
+			name:     "not cors",
+			method:   http.MethodGet,
+			wantCode: http.StatusOK,
+			wantHeader: http.Header{
+				"Access-Control-Allow-Origin": nil,
+			},
+		},
+		{
+			name:     "allowed",
+			method:   http.MethodGet,
+			header:   http.Header{"Origin": {"http://a.com"}},
+			wantCode: http.StatusOK,
+			wantHeader: http.Header{
+				"Access-Control-Allow-Origin": {"http://a.com"},
+				"Vary":                        {"Origin"},
+			},
+		},
+		{
+			name:     "not allowed",
+			method:   http.MethodGet,
+			header:   http.Header{"Origin": {"http://b.com"}},
+			wantCode: http.StatusOK,
+			wantHeader: http.Header{
+				"Access-Control-Allow-Origin": nil,
+			},
+		},
+		{
+			name:   "preflight",
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                        {"http://a.com"},
+				"Access-Control-Request-Method": {"POST"},
+			},
+			wantCode: http.StatusNoContent,
+			wantHeader: http.Header{
+				"Access-Control-Allow-Origin":  {"http://a.com"},
+				"Access-Control-Allow-Methods": {"GET, POST, PUT, PATCH, DELETE, HEAD"},
+				"Access-Control-Max-Age":       {"600"},
+			},
+		},
+		{
+			name:   "preflight not allowed",
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                        {"http://b.com"},
+				"Access-Control-Request-Method": {"POST"},
+			},
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/user", nil)
+			req.Header = tc.header
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			for k, v := range tc.wantHeader {
+				assert.Equal(t, v, recorder.Header().Values(k))
+			}
+		})
+	}
+}
+
+func TestMiddlewareBuilder_Credentials(t *testing.T) {
+	builder := NewBuilder("*")
+	builder.AllowCredentials = true
+	assert.Panics(t, func() {
+		builder.Build()
+	})
+
+	builder = NewBuilder("http://a.com")
+	builder.AllowCredentials = true
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	s.Get("/user", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Origin", "http://a.com")
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, "http://a.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", recorder.Header().Get("Access-Control-Allow-Credentials"))
+}
@@ -0,0 +1,73 @@
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"log"
+	web "myhomework/homework2"
+	"net/http"
+	"strings"
+)
+
+// MiddlewareBuilder 压缩 ctx.RespData
+// 直接写 ctx.Resp 的响应不会被压缩，静态文件的 Range 请求也不会被压缩
+type MiddlewareBuilder struct {
+	level int
+	// minLength 小于这个长度的响应不压缩
+	minLength int
+}
+
+func NewBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		level:     gzip.DefaultCompression,
+		minLength: 1024,
+	}
+}
+
+func (b *MiddlewareBuilder) Level(level int) *MiddlewareBuilder {
+	b.level = level
+	return b
+}
+
+func (b *MiddlewareBuilder) MinLength(minLength int) *MiddlewareBuilder {
+	b.minLength = minLength
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			next(ctx)
+			if !strings.Contains(ctx.Req.Header.Get("Accept-Encoding"), "gzip") {
+				return
+			}
+			header := ctx.Resp.Header()
+			if len(ctx.RespData) < b.minLength || header.Get("Content-Encoding") != "" ||
+				ctx.RespStatusCode == http.StatusPartialContent {
+				return
+			}
+			// 压缩之后 net/http 就无法正确推断类型了
+			if header.Get("Content-Type") == "" {
+				header.Set("Content-Type", http.DetectContentType(ctx.RespData))
+			}
+			buf := &bytes.Buffer{}
+			writer, err := gzip.NewWriterLevel(buf, b.level)
+			if err != nil {
+				log.Println("web: 创建 gzip 失败", err)
+				return
+			}
+			if _, err = writer.Write(ctx.RespData); err != nil {
+				log.Println("web: gzip 压缩失败", err)
+				return
+			}
+			if err = writer.Close(); err != nil {
+				log.Println("web: gzip 压缩失败", err)
+				return
+			}
+			header.Set("Content-Encoding", "gzip")
+			header.Add("Vary", "Accept-Encoding")
+			header.Del("Content-Length")
+			ctx.RespData = buf.Bytes()
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package gzip
+
+import (
+	"compress/gzip"
+	"io"
+	web "myhomework/homework2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	long := strings.Repeat("hello, world ", 100)
+	s := web.NewHTTPServer(web.ServerWithMiddleware(NewBuilder().Build()))
+	s.Get("/long", func(ctx *web.Context) {
+		_ = ctx.RespString(http.StatusOK, long)
+	})
+	s.Get("/short", func(ctx *web.Context) {
+		_ = ctx.RespString(http.StatusOK, "hello")
+	})
+
+	testCases := []struct {
+		name         string
+		path         string
+		acceptGzip   bool
+		wantEncoding string
+	}{
+		{
+			name:         "compressed",
+			path:         "/long",
+			acceptGzip:   true,
+			wantEncoding: "gzip",
+		},
+		{
+			name: "not accept",
+			path: "/long",
+		},
+		{
+			name:       "too short",
+			path:       "/short",
+			acceptGzip: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptGzip {
+				req.Header.Set("Accept-Encoding", "gzip, deflate")
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantEncoding, recorder.Header().Get("Content-Encoding"))
+			assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"))
+			if tc.wantEncoding == "" {
+				return
+			}
+			reader, err := gzip.NewReader(recorder.Body)
+			require.NoError(t, err)
+			data, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, long, string(data))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package login
+
+import (
+	web "myhomework/homework2"
+	"myhomework/homework2/session"
+	"net/http"
+	"net/url"
+)
+
+// MiddlewareBuilder 检查请求有没有登录，没有登录就重定向到登录页面
+// 登录的时候需要调用 Manager.InitSession 创建 session，
+// 已经登录的请求会刷新 session 的过期时间
+type MiddlewareBuilder struct {
+	manager   *session.Manager
+	loginPath string
+	// ignorePaths 不需要登录的路径，登录页面本身总是不需要登录
+	ignorePaths map[string]struct{}
+	// redirectParam 不为空的时候，会把原来的地址放到这个查询参数里面，登录之后可以跳回去
+	redirectParam string
+}
+
+func NewBuilder(manager *session.Manager, loginPath string) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		manager:     manager,
+		loginPath:   loginPath,
+		ignorePaths: map[string]struct{}{loginPath: {}},
+	}
+}
+
+// IgnorePaths 设置不需要登录的路径，例如注册页面和静态资源之外的公开接口
+func (b *MiddlewareBuilder) IgnorePaths(paths ...string) *MiddlewareBuilder {
+	for _, p := range paths {
+		b.ignorePaths[p] = struct{}{}
+	}
+	return b
+}
+
+// RedirectParam 设置保存原地址的查询参数，例如 redirect
+func (b *MiddlewareBuilder) RedirectParam(param string) *MiddlewareBuilder {
+	b.redirectParam = param
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if _, ok := b.ignorePaths[ctx.Req.URL.Path]; ok {
+				next(ctx)
+				return
+			}
+			if err := b.manager.RefreshSession(ctx); err != nil {
+				_ = ctx.RedirectFound(b.location(ctx.Req))
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+func (b *MiddlewareBuilder) location(req *http.Request) string {
+	if b.redirectParam == "" {
+		return b.loginPath
+	}
+	return b.loginPath + "?" + url.Values{b.redirectParam: {req.URL.RequestURI()}}.Encode()
+}
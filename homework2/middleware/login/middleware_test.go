@@ -0,0 +1,71 @@
+package login
+
+import (
+	web "myhomework/homework2"
+	"myhomework/homework2/session"
+	"myhomework/homework2/session/cookie"
+	"myhomework/homework2/session/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	store := memory.NewStore(time.Minute)
+	defer store.Close()
+	m := session.NewManager(store, cookie.NewPropagator())
+
+	builder := NewBuilder(m, "/login").IgnorePaths("/register").RedirectParam("redirect")
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	handler := func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}
+	s.Get("/login", handler)
+	s.Get("/register", handler)
+	s.Get("/profile", handler)
+	s.Post("/login", func(ctx *web.Context) {
+		_, err := m.InitSession(ctx)
+		require.NoError(t, err)
+		ctx.RespStatusCode = http.StatusOK
+	})
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		login        bool
+		wantCode     int
+		wantLocation string
+	}{
+		{name: "login page", method: http.MethodGet, path: "/login", wantCode: http.StatusOK},
+		{name: "ignore", method: http.MethodGet, path: "/register", wantCode: http.StatusOK},
+		{
+			name:         "not login",
+			method:       http.MethodGet,
+			path:         "/profile?tab=1",
+			wantCode:     http.StatusFound,
+			wantLocation: "/login?redirect=%2Fprofile%3Ftab%3D1",
+		},
+		{name: "login", method: http.MethodGet, path: "/profile", login: true, wantCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.login {
+				recorder := httptest.NewRecorder()
+				s.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/login", nil))
+				for _, c := range recorder.Result().Cookies() {
+					req.AddCookie(c)
+				}
+			}
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantLocation, recorder.Header().Get("Location"))
+		})
+	}
+}
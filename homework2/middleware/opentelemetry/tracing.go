@@ -0,0 +1,59 @@
+package opentelemetry
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultInstrumentationName = "myhomework/homework2/middleware/opentelemetry"
+
+type MiddlewareBuilder struct {
+	Tracer trace.Tracer
+	// Propagator 用于从请求头里面提取链路信息，默认使用全局的 Propagator
+	Propagator propagation.TextMapPropagator
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	if b.Tracer == nil {
+		b.Tracer = otel.GetTracerProvider().Tracer(defaultInstrumentationName)
+	}
+	if b.Propagator == nil {
+		b.Propagator = otel.GetTextMapPropagator()
+	}
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			reqCtx := b.Propagator.Extract(ctx.Req.Context(), propagation.HeaderCarrier(ctx.Req.Header))
+			// 用命中的路由作为 span 的名字，避免名字的数量爆炸
+			spanName := ctx.MatchedRoute
+			if spanName == "" {
+				spanName = "unknown"
+			}
+			reqCtx, span := b.Tracer.Start(reqCtx, spanName, trace.WithSpanKind(trace.SpanKindServer))
+			defer span.End()
+
+			span.SetAttributes(attribute.String("http.method", ctx.Req.Method))
+			span.SetAttributes(attribute.String("http.url", ctx.Req.URL.String()))
+			span.SetAttributes(attribute.String("http.scheme", ctx.Req.URL.Scheme))
+			span.SetAttributes(attribute.String("http.host", ctx.Req.Host))
+			span.SetAttributes(attribute.String("http.route", ctx.MatchedRoute))
+
+			ctx.Req = ctx.Req.WithContext(reqCtx)
+			next(ctx)
+
+			status := ctx.RespStatusCode
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(attribute.Int("http.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		}
+	}
+}
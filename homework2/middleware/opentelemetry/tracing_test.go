@@ -0,0 +1,31 @@
+package opentelemetry
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	builder := &MiddlewareBuilder{
+		Propagator: propagation.TraceContext{},
+	}
+	var spanCtx trace.SpanContext
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	s.Get("/user/:id", func(ctx *web.Context) {
+		spanCtx = trace.SpanContextFromContext(ctx.Req.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/123", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 默认的 TracerProvider 不会创建新的 span，但是会沿用上游的链路信息
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanCtx.TraceID().String())
+	assert.True(t, spanCtx.IsRemote())
+}
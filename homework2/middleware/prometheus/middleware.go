@@ -0,0 +1,76 @@
+package prometheus
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MiddlewareBuilder 统计请求的耗时和正在处理的请求数量
+// 标签里面用的是命中的路由，例如 /user/:id，而不是请求的路径，
+// 否则每一个不同的 id 都会产生一条新的时间序列
+type MiddlewareBuilder struct {
+	Namespace   string
+	Subsystem   string
+	Name        string
+	Help        string
+	ConstLabels map[string]string
+	// Buckets 耗时分布的区间，单位秒，默认是 prometheus.DefBuckets
+	Buckets []float64
+	// Registerer 默认是 prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+}
+
+func (m *MiddlewareBuilder) Build() web.Middleware {
+	buckets := m.Buckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+	registerer := m.Registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	durationVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   m.Namespace,
+		Subsystem:   m.Subsystem,
+		Name:        m.Name + "_duration_seconds",
+		Help:        m.Help,
+		ConstLabels: m.ConstLabels,
+		Buckets:     buckets,
+	}, []string{"route", "method", "status"})
+	inFlightVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   m.Namespace,
+		Subsystem:   m.Subsystem,
+		Name:        m.Name + "_in_flight",
+		Help:        m.Help,
+		ConstLabels: m.ConstLabels,
+	}, []string{"route", "method"})
+	registerer.MustRegister(durationVec, inFlightVec)
+
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			route := ctx.MatchedRoute
+			if route == "" {
+				route = "unknown"
+			}
+			method := ctx.Req.Method
+			startTime := time.Now()
+			inFlight := inFlightVec.WithLabelValues(route, method)
+			inFlight.Inc()
+			defer func() {
+				inFlight.Dec()
+				status := ctx.RespStatusCode
+				// 直接写 ctx.Resp 的时候没有设置响应码，默认就是 200
+				if status == 0 {
+					status = http.StatusOK
+				}
+				durationVec.WithLabelValues(route, method, strconv.Itoa(status)).
+					Observe(time.Since(startTime).Seconds())
+			}()
+			next(ctx)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package prometheus
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := &MiddlewareBuilder{
+		Namespace:  "myhomework",
+		Subsystem:  "web",
+		Name:       "http_request",
+		Help:       "HTTP 请求",
+		Registerer: registry,
+	}
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	s.Get("/user/:id", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+	})
+
+	for _, path := range []string{"/user/1", "/user/2", "/user/3", "/order"} {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	mfs, err := registry.Gather()
+	require.NoError(t, err)
+	var durations map[string]uint64
+	for _, mf := range mfs {
+		if mf.GetName() != "myhomework_web_http_request_duration_seconds" {
+			continue
+		}
+		durations = make(map[string]uint64, len(mf.GetMetric()))
+		for _, m := range mf.GetMetric() {
+			labels := ""
+			for _, l := range m.GetLabel() {
+				labels += l.GetName() + "=" + l.GetValue() + ","
+			}
+			durations[labels] = m.GetHistogram().GetSampleCount()
+		}
+	}
+	// 不同的 id 都归到同一个路由下面
+	assert.Equal(t, map[string]uint64{
+		"method=GET,route=/user/:id,status=200,": 3,
+		"method=GET,route=unknown,status=404,":   1,
+	}, durations)
+	assert.Equal(t, 2, testutil.CollectAndCount(registry, "myhomework_web_http_request_in_flight"))
+}
@@ -0,0 +1,42 @@
+package recovery
+
+import (
+	"log"
+	web "myhomework/homework2"
+	"net/http"
+	"runtime/debug"
+)
+
+// MiddlewareBuilder 捕获 handler 里面的 panic，返回 StatusCode 和 Data
+type MiddlewareBuilder struct {
+	StatusCode int
+	Data       []byte
+	LogFunc    func(ctx *web.Context, err any)
+}
+
+func NewBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		StatusCode: http.StatusInternalServerError,
+		Data:       []byte("Internal Server Error"),
+		LogFunc: func(ctx *web.Context, err any) {
+			log.Printf("web: panic 路径 %s, 错误 %v\n%s", ctx.Req.URL.Path, err, debug.Stack())
+		},
+	}
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			defer func() {
+				if err := recover(); err != nil {
+					ctx.RespStatusCode = b.StatusCode
+					ctx.RespData = b.Data
+					if b.LogFunc != nil {
+						b.LogFunc(ctx, err)
+					}
+				}
+			}()
+			next(ctx)
+		}
+	}
+}
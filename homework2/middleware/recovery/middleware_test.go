@@ -0,0 +1,28 @@
+package recovery
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	var panicErr any
+	builder := NewBuilder()
+	builder.LogFunc = func(ctx *web.Context, err any) {
+		panicErr = err
+	}
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	s.Get("/panic", func(ctx *web.Context) {
+		panic("boom")
+	})
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "Internal Server Error", recorder.Body.String())
+	assert.Equal(t, "boom", panicErr)
+}
@@ -0,0 +1,69 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	web "myhomework/homework2"
+)
+
+const defaultHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// MiddlewareBuilder 如果请求里面带了 request id 就沿用，否则生成一个新的
+// request id 会写回响应头，并且放到 ctx.Req.Context() 里面，
+// 调用下游的时候可以用 FromContext 取出来继续传递
+type MiddlewareBuilder struct {
+	header    string
+	generator func() string
+}
+
+func NewBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		header:    defaultHeader,
+		generator: generate,
+	}
+}
+
+func (b *MiddlewareBuilder) Header(header string) *MiddlewareBuilder {
+	b.header = header
+	return b
+}
+
+func (b *MiddlewareBuilder) Generator(fn func() string) *MiddlewareBuilder {
+	b.generator = fn
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			id := ctx.Req.Header.Get(b.header)
+			if id == "" {
+				id = b.generator()
+				ctx.Req.Header.Set(b.header, id)
+			}
+			ctx.Resp.Header().Set(b.header, id)
+			ctx.Req = ctx.Req.WithContext(NewContext(ctx.Req.Context(), id))
+			next(ctx)
+		}
+	}
+}
+
+// NewContext 把 request id 放到 context 里面
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext 从 context 里面取出 request id
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+func generate() string {
+	var bs [16]byte
+	_, _ = rand.Read(bs[:])
+	return hex.EncodeToString(bs[:])
+}
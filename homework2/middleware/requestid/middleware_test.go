@@ -0,0 +1,37 @@
+package requestid
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	var gotID string
+	builder := NewBuilder().Generator(func() string {
+		return "generated"
+	})
+	s := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
+	s.Get("/user", func(ctx *web.Context) {
+		gotID, _ = FromContext(ctx.Req.Context())
+	})
+
+	// 没有就生成
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, "generated", recorder.Header().Get(defaultHeader))
+	assert.Equal(t, "generated", gotID)
+
+	// 有就沿用
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set(defaultHeader, "abc")
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, "abc", recorder.Header().Get(defaultHeader))
+	assert.Equal(t, "abc", gotID)
+
+	assert.Len(t, generate(), 32)
+}
@@ -0,0 +1,50 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	web "myhomework/homework2"
+	"net/http"
+	"time"
+)
+
+// MiddlewareBuilder 给请求的 context 设置超时时间
+// 一般通过 HTTPServer.Use 注册在某些路由上
+// 超时之后 context 会被取消，但是 handler 不会被强制中断，
+// 所以 handler 需要自己监听 ctx.Req.Context()，
+// 只要 handler 返回的时候已经超时，就会覆盖响应
+type MiddlewareBuilder struct {
+	timeout    time.Duration
+	statusCode int
+	data       []byte
+}
+
+func NewBuilder(timeout time.Duration) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		timeout:    timeout,
+		statusCode: http.StatusGatewayTimeout,
+		data:       []byte("Gateway Timeout"),
+	}
+}
+
+// Resp 设置超时之后的响应
+func (b *MiddlewareBuilder) Resp(statusCode int, data []byte) *MiddlewareBuilder {
+	b.statusCode = statusCode
+	b.data = data
+	return b
+}
+
+func (b *MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			reqCtx, cancel := context.WithTimeout(ctx.Req.Context(), b.timeout)
+			defer cancel()
+			ctx.Req = ctx.Req.WithContext(reqCtx)
+			next(ctx)
+			if errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+				ctx.RespStatusCode = b.statusCode
+				ctx.RespData = b.data
+			}
+		}
+	}
+}
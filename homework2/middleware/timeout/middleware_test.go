@@ -0,0 +1,39 @@
+package timeout
+
+import (
+	web "myhomework/homework2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	s := web.NewHTTPServer()
+	s.Use(http.MethodGet, "/slow", NewBuilder(10*time.Millisecond).Build())
+	s.Get("/slow", func(ctx *web.Context) {
+		select {
+		case <-ctx.Req.Context().Done():
+		case <-time.After(time.Second):
+		}
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("slow")
+	})
+	s.Get("/fast", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("fast")
+	})
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+	assert.Equal(t, "Gateway Timeout", recorder.Body.String())
+
+	// 只作用于注册的路由
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "fast", recorder.Body.String())
+}
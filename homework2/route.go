@@ -2,7 +2,9 @@ package web
 
 import (
 	"fmt"
+	"net/http"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -10,11 +12,18 @@ type router struct {
 	// trees 是按照 HTTP 方法来组织的
 	// 如 GET => *node
 	trees map[string]*node
+
+	// names 命名路由，名字 => 注册时候的路由
+	names map[string]string
+	// hasMws 记录哪些 HTTP 方法的路由树注册了 middleware
+	hasMws map[string]bool
 }
 
 func newRouter() router {
 	return router{
-		trees: map[string]*node{},
+		trees:  map[string]*node{},
+		names:  map[string]string{},
+		hasMws: map[string]bool{},
 	}
 }
 
@@ -25,6 +34,9 @@ func newRouter() router {
 // - 不能在同一个位置注册不同的参数路由，例如 /user/:id 和 /user/:name 冲突
 // - 不能在同一个位置同时注册通配符路由和参数路由，例如 /user/:id 和 /user/* 冲突
 // - 同名路径参数，在路由匹配的时候，值会被覆盖。例如 /user/:id/abc/:id，那么 /user/123/abc/456 最终 id = 456
+// - 命名通配符 *name 只能是最后一段，会把剩下的路径都放到 name 参数里面，例如 /static/*filepath
+// - 路径参数可以带上类型约束，例如 :id<int>，支持的类型见 paramConstraints
+// - 最后一段路径参数可以是可选的，例如 /user/:id? 相当于同时注册了 /user 和 /user/:id
 func (r *router) addRoute(method string, path string, handler HandleFunc, mws ...Middleware) {
 	if path == "" {
 		panic("web: 路由是空字符串")
@@ -37,131 +49,270 @@ func (r *router) addRoute(method string, path string, handler HandleFunc, mws ..
 		panic("web: 路由不能以 / 结尾")
 	}
 
+	if path[len(path)-1] == '?' {
+		idx := strings.LastIndexByte(path, '/')
+		if last := path[idx+1:]; len(last) < 3 || last[0] != ':' {
+			panic(fmt.Sprintf("web: 非法路由，只有最后一段路径参数可以是可选的 [%s]", path))
+		}
+		base := path[:idx]
+		if base == "" {
+			base = "/"
+		}
+		r.register(method, base, path, handler, mws)
+		r.register(method, path[:len(path)-1], path, handler, mws)
+		return
+	}
+	r.register(method, path, path, handler, mws)
+}
+
+// register 注册路由，route 是用户注册时候的原始路由
+// 例如可选参数 /user/:id? 会注册两次，但是 route 都是 /user/:id?
+func (r *router) register(method string, path string, route string, handler HandleFunc, mws []Middleware) {
+	if len(mws) > 0 {
+		r.hasMws[method] = true
+	}
+
 	root, ok := r.trees[method]
 	// 这是一个全新的 HTTP 方法，创建根节点
 	if !ok {
-		// 创建根节点
-		root = &node{path: "/"}
+		// 根节点的 path 是空字符串，静态子节点都以 / 开头
+		root = &node{}
 		r.trees[method] = root
 
 	}
 	if path == "/" {
-		if root.handler != nil {
-			panic("web: 路由冲突[/]")
-		}
-		root.handler = handler
-		if len(mws) > 0 {
-			root.mws = append(root.mws, mws...)
-		}
+		root.setHandler(route, handler, mws)
 		return
 	}
 
 	segs := strings.Split(path[1:], "/")
-	// 开始一段段处理
-	for _, s := range segs {
+	// 开始一段段处理，k 是当前节点的 path 已经匹配了的长度
+	current, k := root, 0
+	for i, s := range segs {
 		if s == "" {
 			panic(fmt.Sprintf("web: 非法路由。不允许使用 //a/b, /a//b 之类的路由, [%s]", path))
 		}
-		root = root.childOrCreate(s)
-	}
-	if root.handler != nil {
-		panic(fmt.Sprintf("web: 路由冲突[%s]", path))
-	}
-	root.handler = handler
-	if len(mws) > 0 {
-		root.mws = append(root.mws, mws...)
+		if s[0] == '*' && len(s) > 1 && i != len(segs)-1 {
+			panic(fmt.Sprintf("web: 非法路由，命名通配符只能是最后一段 [%s]", path))
+		}
+		if s[0] != '*' && s[0] != ':' {
+			current, k = current.insertStatic(k, "/"+s)
+			continue
+		}
+		// 非静态节点挂在以 / 结尾的静态节点下面
+		current, k = current.insertStatic(k, "/")
+		current = current.splitAt(k).childOrCreate(s)
+		k = len(current.path)
 	}
+	current.splitAt(k).setHandler(route, handler, mws)
 }
 
 // findRoute 查找对应的节点
 // 注意，返回的 node 内部 HandleFunc 不为 nil 才算是注册了路由
 func (r *router) findRoute(method string, path string) (*matchInfo, bool) {
+	mi := &matchInfo{}
+	if !r.findRouteInto(method, path, mi) {
+		return nil, false
+	}
+	return mi, true
+}
+
+// findRouteInto 和 findRoute 一样，但是结果写入 mi，这样 mi 就可以被复用
+// 使用 mi 之前要调用 reset。在 mi 已经被用过的情况下，
+// 静态路由和参数路由的查找不会分配内存
+func (r *router) findRouteInto(method string, path string, mi *matchInfo) bool {
 	root, ok := r.trees[method]
 	if !ok {
-		return nil, false
+		return false
 	}
 
 	if path == "/" {
-		return &matchInfo{n: root, mws: root.mws}, true
+		mi.n = root
+		mi.mws = root.mws
+		return true
 	}
 
-	segs := strings.Split(strings.Trim(path, "/"), "/")
-	mi := &matchInfo{}
-	current := root
-	for _, s := range segs {
-		var child *node
-		child, ok = current.childOf(s)
+	// 不使用 strings.Split，直接在原始字符串上切片，避免分配内存
+	trimmed := strings.Trim(path, "/")
+	current, k := root, 0
+	for start := 0; start <= len(trimmed); {
+		end := strings.IndexByte(trimmed[start:], '/')
+		if end < 0 {
+			end = len(trimmed)
+		} else {
+			end += start
+		}
+		s := trimmed[start:end]
+		child, j, ok := current.childOf(k, s)
 		if !ok {
 			if current.typ == nodeTypeAny {
 				mi.n = current
-				mi.mws = r.findMdls(current, segs)
-				return mi, true
+				r.findMdls(method, root, trimmed, mi)
+				return true
 			}
-			return nil, false
+			return false
+		}
+		// 命名通配符，剩下的路径都是它的
+		if child.typ == nodeTypeAny && child.paramName != "" {
+			mi.addValue(child.paramName, trimmed[start:])
+			mi.n = child
+			r.findMdls(method, root, trimmed, mi)
+			return true
 		}
 		if child.paramName != "" {
 			mi.addValue(child.paramName, s)
 		}
-		current = child
+		current, k = child, j
+		start = end + 1
 	}
-	mi.n = current
-	mi.mws = r.findMdls(root, segs)
-
-	//fmt.Println("mi.mws:", mi.mws)
+	mi.n = current.nodeAt(k)
+	r.findMdls(method, root, trimmed, mi)
+	return true
+}
 
+// findHandler 查找注册了 handler 的路由
+func (r *router) findHandler(method string, path string) (*matchInfo, bool) {
+	mi, ok := r.findRoute(method, path)
+	if !ok || mi.n == nil || mi.n.handler == nil {
+		return nil, false
+	}
 	return mi, true
 }
 
-func (r *router) findMdls(root *node, segs []string) []Middleware {
-	// 层次遍历
-	useMws := make([]Middleware, 0, 4)
+// findHandlerInto 查找注册了 handler 的路由，结果写入 mi
+// 没有找到的时候 mi 会被清空
+func (r *router) findHandlerInto(method string, path string, mi *matchInfo) bool {
+	mi.reset()
+	if !r.findRouteInto(method, path, mi) || mi.n == nil || mi.n.handler == nil {
+		mi.reset()
+		return false
+	}
+	return true
+}
+
+// findCaseInsensitive 忽略大小写查找路由
+// 返回的是按照注册时候的大小写修正之后的路径，参数和通配符部分保持原样
+func (r *router) findCaseInsensitive(method string, path string) (string, bool) {
+	root, ok := r.trees[method]
+	if !ok {
+		return "", false
+	}
+	if path == "/" {
+		return path, root.handler != nil
+	}
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	var sb strings.Builder
+	current, k := root, 0
+	for i, seg := range segs {
+		child, j, fixed, ok := current.childOfFold(k, seg)
+		if !ok {
+			if current.typ == nodeTypeAny {
+				sb.WriteByte('/')
+				sb.WriteString(strings.Join(segs[i:], "/"))
+				return sb.String(), current.handler != nil
+			}
+			return "", false
+		}
+		sb.WriteByte('/')
+		if child.typ == nodeTypeAny && child.paramName != "" {
+			sb.WriteString(strings.Join(segs[i:], "/"))
+			return sb.String(), child.handler != nil
+		}
+		sb.WriteString(fixed)
+		current, k = child, j
+	}
+	return sb.String(), current.nodeAt(k).handler != nil
+}
 
-	nodePathList := make([]*node, 0, 4)
-	nodePathList = append(nodePathList, root)
+// allowedMethods 返回 path 上注册了路由的 HTTP 方法
+// 注册了 GET 就意味着支持 HEAD，只要有任何一个方法就支持 OPTIONS
+func (r *router) allowedMethods(path string) []string {
+	var res []string
+	for method := range r.trees {
+		if _, ok := r.findHandler(method, path); ok {
+			res = append(res, method)
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	contains := func(method string) bool {
+		for _, m := range res {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+	if contains(http.MethodGet) && !contains(http.MethodHead) {
+		res = append(res, http.MethodHead)
+	}
+	if !contains(http.MethodOptions) {
+		res = append(res, http.MethodOptions)
+	}
+	sort.Strings(res)
+	return res
+}
 
-	if len(root.mws) > 0 {
-		useMws = append(useMws, root.mws...)
+// findMdls 按层查找所有匹配的节点上的 middleware，结果放在 mi.mws 里面
+// 每一层的顺序是：通配符、正则、参数、静态
+// 没有注册过 middleware 的路由树直接跳过
+func (r *router) findMdls(method string, root *node, trimmed string, mi *matchInfo) {
+	if !r.hasMws[method] {
+		mi.mws = nil
+		return
 	}
+	// 层次遍历
+	useMws := append(mi.mwsBuf[:0], root.mws...)
 
-	for _, seg := range segs {
-		matchChildrenNodes := make([]*node, 0, 4)
-		for _, n := range nodePathList {
-			if n.starChild != nil {
-				if len(n.starChild.mws) > 0 {
-					useMws = append(useMws, n.starChild.mws...)
-				}
-				matchChildrenNodes = append(matchChildrenNodes, n.starChild)
+	positions := append(mi.positions[:0], position{n: root})
+	matchChildren := mi.nextPositions[:0]
 
-			} else if n.regChild != nil {
-				if n.regChild.regExpr.Match([]byte(seg)) {
-					if len(n.regChild.mws) > 0 {
-						useMws = append(useMws, n.regChild.mws...)
+	for start := 0; start <= len(trimmed); {
+		end := strings.IndexByte(trimmed[start:], '/')
+		if end < 0 {
+			end = len(trimmed)
+		} else {
+			end += start
+		}
+		seg := trimmed[start:end]
+		start = end + 1
+
+		matchChildren = matchChildren[:0]
+		for _, p := range positions {
+			if sn := p.n.slashNode(p.k); sn != nil {
+				var child *node
+				if sn.starChild != nil {
+					child = sn.starChild
+				} else if sn.regChild != nil {
+					if sn.regChild.regExpr.MatchString(seg) {
+						child = sn.regChild
 					}
-					matchChildrenNodes = append(matchChildrenNodes, n.regChild)
+				} else if sn.paramChild != nil {
+					child = sn.paramChild
 				}
-			} else if n.paramChild != nil {
-				if len(n.paramChild.mws) > 0 {
-					useMws = append(useMws, n.paramChild.mws...)
+				if child != nil {
+					useMws = append(useMws, child.mws...)
+					matchChildren = append(matchChildren, position{n: child, k: len(child.path)})
 				}
-				matchChildrenNodes = append(matchChildrenNodes, n.paramChild)
 			}
 
-			if len(n.children) > 0 {
-				if v, ok := n.children[seg]; ok {
-					if len(v.mws) > 0 {
-						useMws = append(useMws, v.mws...)
-					}
-					matchChildrenNodes = append(matchChildrenNodes, v)
-				}
+			if n, k, ok := p.n.matchStatic(p.k, seg); ok && n.isSegmentEnd(k) {
+				useMws = append(useMws, n.nodeAt(k).mws...)
+				matchChildren = append(matchChildren, position{n: n, k: k})
 			}
 		}
-
-		//nodePathList = append(nodePathList, matchChildrenNodes...)
-		nodePathList = matchChildrenNodes
-
+		// 两个切片交替使用
+		positions, matchChildren = matchChildren, positions
 	}
 
-	return useMws
+	mi.positions, mi.nextPositions = positions, matchChildren
+	mi.mwsBuf = useMws
+	if len(useMws) == 0 {
+		mi.mws = nil
+		return
+	}
+	mi.mws = useMws
 }
 
 type nodeType int
@@ -177,20 +328,28 @@ const (
 	nodeTypeAny
 )
 
-// node 代表路由树的节点
-// 路由树的匹配顺序是：
+// node 代表路由树的节点，路由树是压缩前缀树（radix tree）
+// 静态节点的 path 是压缩之后的一段路径，包含 /，例如 /user/home，
+// 只在分叉、注册了路由以及挂着非静态子节点的地方拆分。
+// 非静态节点的 path 是注册时候的一段，例如 :id、*，
+// 它们挂在以 / 结尾的静态节点下面。根节点的 path 是空字符串
+//
+// 按段来看，每一段路径的匹配顺序是：
 // 1. 静态完全匹配
 // 2. 正则匹配，形式 :param_name(reg_expr)
 // 3. 路径参数匹配：形式 :param_name
-// 4. 通配符匹配：*
+// 4. 通配符匹配：* 或者命名通配符 *name
 // 这是不回溯匹配
 type node struct {
 	typ nodeType
 
 	path string
-	// children 子节点
-	// 子节点的 path => node
-	children map[string]*node
+	// indices 静态子节点 path 的第一个字节，和 children 一一对应
+	indices string
+	// children 静态子节点
+	children []*node
+	// registered 有路由在这个节点结束，包括只注册了 middleware 的路由
+	registered bool
 	// handler 命中路由之后执行的逻辑
 	handler HandleFunc
 
@@ -198,7 +357,7 @@ type node struct {
 	starChild *node
 
 	paramChild *node
-	// 正则路由和参数路由都会使用这个字段
+	// 正则路由、参数路由和命名通配符路由都会使用这个字段
 	paramName string
 
 	// 正则表达式
@@ -206,26 +365,164 @@ type node struct {
 	regExpr  *regexp.Regexp
 
 	mws []Middleware
+
+	// route 是注册时候的完整路由，例如 /user/:id
+	route string
 }
 
-// child 返回子节点
-// 第一个返回值 *node 是命中的节点
-// 第二个返回值 bool 代表是否命中
-func (n *node) childOf(path string) (*node, bool) {
-	if n.children == nil {
-		return n.childOfNonStatic(path)
+// intermediate 压缩在静态节点中间的一段路径，例如只注册了 /order/create 的时候的 /order，
+// 相当于没有 handler 和 middleware 的节点
+var intermediate = &node{typ: nodeTypeStatic}
+
+// position 路由树里面的一个位置，k 是 n.path 已经匹配了的长度
+// 压缩之后，一段路径的结尾可能在静态节点的中间
+type position struct {
+	n *node
+	k int
+}
+
+// nodeAt 位置 k 对应的节点，在节点中间的时候返回 intermediate
+func (n *node) nodeAt(k int) *node {
+	if k < len(n.path) {
+		return intermediate
 	}
-	res, ok := n.children[path]
-	if !ok {
-		return n.childOfNonStatic(path)
+	return n
+}
+
+// staticChild 查找第一个字节是 c 的静态子节点
+func (n *node) staticChild(c byte) *node {
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] == c {
+			return n.children[i]
+		}
+	}
+	return nil
+}
+
+// next 从 n.path[k] 开始匹配一个字节，到了节点结尾就从静态子节点里面找
+func (n *node) next(k int, c byte) (*node, int, bool) {
+	if k < len(n.path) {
+		return n, k + 1, n.path[k] == c
+	}
+	child := n.staticChild(c)
+	if child == nil {
+		return nil, 0, false
+	}
+	return child, 1, true
+}
+
+// matchStatic 从 n.path[k] 开始匹配 / 和 seg，返回匹配之后的位置
+func (n *node) matchStatic(k int, seg string) (*node, int, bool) {
+	n, k, ok := n.next(k, '/')
+	for i := 0; ok && i < len(seg); i++ {
+		n, k, ok = n.next(k, seg[i])
+	}
+	return n, k, ok
+}
+
+// isSegmentEnd 位置 k 是不是某个路由里面一段的结尾
+// 例如只注册了 /users 和 /userx 的时候，拆分出来的 /user 节点的结尾就不是
+func (n *node) isSegmentEnd(k int) bool {
+	if k < len(n.path) {
+		return n.path[k] == '/'
+	}
+	return n.path == "" || n.typ != nodeTypeStatic || n.registered || n.staticChild('/') != nil
+}
+
+// slashNode 位置 k 后面的 / 对应的节点，非静态子节点都挂在它上面
+// / 在静态节点中间的时候，说明没有非静态子节点，返回 nil
+func (n *node) slashNode(k int) *node {
+	sn, j, ok := n.next(k, '/')
+	if !ok || j < len(sn.path) {
+		return nil
+	}
+	return sn
+}
+
+// childOf 在位置 k 后面查找 path 这一段对应的子节点，先静态后非静态
+// 第一个返回值 *node 和第二个返回值 int 是命中的位置
+// 第三个返回值 bool 代表是否命中
+func (n *node) childOf(k int, path string) (*node, int, bool) {
+	if child, j, ok := n.matchStatic(k, path); ok && child.isSegmentEnd(j) {
+		return child, j, true
+	}
+	if sn := n.slashNode(k); sn != nil {
+		if child, ok := sn.childOfNonStatic(path); ok {
+			return child, len(child.path), true
+		}
+	}
+	return nil, 0, false
+}
+
+// childOfFold 和 childOf 类似，但是静态路由忽略大小写，第三个返回值是修正大小写之后的 path
+// 有多个静态路由只是大小写不同的时候，选择字典序最小的那个，保证结果稳定
+func (n *node) childOfFold(k int, path string) (*node, int, string, bool) {
+	if child, j, ok := n.matchStatic(k, path); ok && child.isSegmentEnd(j) {
+		return child, j, path, true
+	}
+	var (
+		res   *node
+		resK  int
+		fixed string
+	)
+	if start, j, ok := n.next(k, '/'); ok {
+		start.eachSegment(j, nil, func(seg string, sn *node, sk int) {
+			if strings.EqualFold(seg, path) && (res == nil || seg < fixed) {
+				res, resK, fixed = sn, sk, seg
+			}
+		})
+	}
+	if res != nil {
+		return res, resK, fixed, true
+	}
+	if sn := n.slashNode(k); sn != nil {
+		if child, ok := sn.childOfNonStatic(path); ok {
+			return child, len(child.path), path, true
+		}
+	}
+	return nil, 0, "", false
+}
+
+// eachSegment 从位置 k 开始，遍历到下一个 / 为止的所有静态路径
+// prefix 是已经遍历过的部分
+func (n *node) eachSegment(k int, prefix []byte, fn func(seg string, n *node, k int)) {
+	for ; k < len(n.path); k++ {
+		if n.path[k] == '/' {
+			fn(string(prefix), n, k)
+			return
+		}
+		prefix = append(prefix, n.path[k])
+	}
+	if n.isSegmentEnd(k) {
+		fn(string(prefix), n, k)
+	}
+	for i, child := range n.children {
+		if n.indices[i] != '/' {
+			child.eachSegment(0, prefix, fn)
+		}
+	}
+}
+
+// setHandler 设置 handler 和 middleware
+// handler 为 nil 的时候说明只是注册 middleware，例如 Use，这时候不会和已有的路由冲突
+func (n *node) setHandler(path string, handler HandleFunc, mws []Middleware) {
+	n.registered = true
+	if handler != nil {
+		if n.handler != nil {
+			panic(fmt.Sprintf("web: 路由冲突[%s]", path))
+		}
+		n.handler = handler
+		n.route = path
+	}
+	if len(mws) > 0 {
+		n.mws = append(n.mws, mws...)
 	}
-	return res, ok
 }
 
 // childOfNonStatic 从非静态匹配的子节点里面查找
 func (n *node) childOfNonStatic(path string) (*node, bool) {
 	if n.regChild != nil {
-		if n.regChild.regExpr.Match([]byte(path)) {
+		if n.regChild.regExpr.MatchString(path) {
 			return n.regChild, true
 		}
 	}
@@ -235,43 +532,98 @@ func (n *node) childOfNonStatic(path string) (*node, bool) {
 	return n.starChild, n.starChild != nil
 }
 
-// childOrCreate 查找子节点，
+// insertStatic 从 n.path[k] 开始插入静态路径 path，返回插入之后的位置
+// 只在分叉的地方拆分节点，所以返回的位置可能在节点的中间
+func (n *node) insertStatic(k int, path string) (*node, int) {
+	for len(path) > 0 {
+		if k < len(n.path) {
+			i := 0
+			for i < len(path) && k+i < len(n.path) && path[i] == n.path[k+i] {
+				i++
+			}
+			if i == len(path) {
+				return n, k + i
+			}
+			if k+i < len(n.path) {
+				// 分叉了，拆分之后加一个新的子节点
+				child := &node{path: path[i:], typ: nodeTypeStatic}
+				n.splitAt(k + i).addChild(child)
+				return child, len(child.path)
+			}
+			k, path = len(n.path), path[i:]
+		}
+		child := n.staticChild(path[0])
+		if child == nil && n.isBareLeaf() {
+			// 刚创建的叶子节点直接延长，不然一个路由里面连续的静态段会变成一串只有一个子节点的节点
+			n.path += path
+			return n, len(n.path)
+		}
+		if child == nil {
+			child = &node{path: path, typ: nodeTypeStatic}
+			n.addChild(child)
+			return child, len(path)
+		}
+		n, k = child, 0
+	}
+	return n, k
+}
+
+// isBareLeaf 没有子节点，也没有注册过路由的静态节点，只会在注册路由的过程中出现
+func (n *node) isBareLeaf() bool {
+	return n.typ == nodeTypeStatic && n.path != "" && !n.registered && len(n.children) == 0 &&
+		n.starChild == nil && n.paramChild == nil && n.regChild == nil
+}
+
+// splitAt 在 path[k] 的位置拆分静态节点，后半部分成为唯一的子节点，
+// 原来的子节点、handler 之类的都交给它，返回的是前半部分，也就是 n 本身
+func (n *node) splitAt(k int) *node {
+	if k >= len(n.path) {
+		return n
+	}
+	child := *n
+	child.path = n.path[k:]
+	*n = node{
+		typ:      nodeTypeStatic,
+		path:     n.path[:k],
+		indices:  child.path[:1],
+		children: []*node{&child},
+	}
+	return n
+}
+
+func (n *node) addChild(child *node) {
+	n.indices += child.path[:1]
+	n.children = append(n.children, child)
+}
+
+// childOrCreate 查找或者创建非静态子节点，
 // 首先会判断 path 是不是通配符路径
-// 其次判断 path 是不是参数路径，即以 : 开头的路径
-// 最后会从 children 里面查找，
-// 如果没有找到，那么会创建一个新的节点，并且保存在 node 里面
+// 其次判断 path 是不是参数路径，即以 : 开头的路径，进一步区分正则路由和参数路由
+// 静态路径使用 insertStatic
 func (n *node) childOrCreate(path string) *node {
-	if path == "*" {
+	if path[0] == '*' {
 		if n.paramChild != nil {
 			panic(fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册通配符路由和参数路由 [%s]", path))
 		}
 		if n.regChild != nil {
 			panic(fmt.Sprintf("web: 非法路由，已有正则路由。不允许同时注册通配符路由和正则路由 [%s]", path))
 		}
-		if n.starChild == nil {
-			n.starChild = &node{path: path, typ: nodeTypeAny}
+		if n.starChild != nil {
+			if n.starChild.path != path {
+				panic(fmt.Sprintf("web: 路由冲突，通配符路由冲突，已有 %s，新注册 %s", n.starChild.path, path))
+			}
+		} else {
+			n.starChild = &node{path: path, paramName: path[1:], typ: nodeTypeAny}
 		}
 		return n.starChild
 	}
 
 	// 以 : 开头，需要进一步解析，判断是参数路由还是正则路由
-	if path[0] == ':' {
-		paramName, expr, isReg := n.parseParam(path)
-		if isReg {
-			return n.childOrCreateReg(path, expr, paramName)
-		}
-		return n.childOrCreateParam(path, paramName)
-	}
-
-	if n.children == nil {
-		n.children = make(map[string]*node)
-	}
-	child, ok := n.children[path]
-	if !ok {
-		child = &node{path: path, typ: nodeTypeStatic}
-		n.children[path] = child
+	paramName, expr, isReg := parseParam(path)
+	if isReg {
+		return n.childOrCreateReg(path, expr, paramName)
 	}
-	return child
+	return n.childOrCreateParam(path, paramName)
 }
 
 func (n *node) childOrCreateParam(path string, paramName string) *node {
@@ -299,7 +651,7 @@ func (n *node) childOrCreateReg(path string, expr string, paramName string) *nod
 		panic(fmt.Sprintf("web: 非法路由，已有路径参数路由。不允许同时注册正则路由和参数路由 [%s]", path))
 	}
 	if n.regChild != nil {
-		if n.regChild.regExpr.String() != expr || n.paramName != paramName {
+		if n.regChild.regExpr.String() != expr || n.regChild.paramName != paramName {
 			panic(fmt.Sprintf("web: 路由冲突，正则路由冲突，已有 %s，新注册 %s", n.regChild.path, path))
 		}
 	} else {
@@ -312,13 +664,31 @@ func (n *node) childOrCreateReg(path string, expr string, paramName string) *nod
 	return n.regChild
 }
 
+// paramConstraints 路径参数的类型约束，例如 :id<int>
+// 约束会被转化为完整匹配的正则表达式
+var paramConstraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
 // parseParam 用于解析判断是不是正则表达式
 // 第一个返回值是参数名字
 // 第二个返回值是正则表达式
-// 第三个返回值为 true 则说明是正则路由
-func (n *node) parseParam(path string) (string, string, bool) {
+// 第三个返回值为 true 则说明是正则路由，类型约束也是正则路由
+func parseParam(path string) (string, string, bool) {
 	// 去除 :
 	path = path[1:]
+	if idx := strings.IndexByte(path, '<'); idx > 0 && strings.HasSuffix(path, ">") {
+		typ := path[idx+1 : len(path)-1]
+		expr, ok := paramConstraints[typ]
+		if !ok {
+			panic(fmt.Sprintf("web: 非法路由，不支持的参数类型 %s", typ))
+		}
+		return path[:idx], "^(?:" + expr + ")$", true
+	}
 	segs := strings.SplitN(path, "(", 2)
 	if len(segs) == 2 {
 		expr := segs[1]
@@ -333,6 +703,11 @@ type matchInfo struct {
 	n          *node
 	pathParams map[string]string
 	mws        []Middleware
+
+	// 下面这些字段是 findMdls 用的缓冲区，复用 matchInfo 的时候可以避免重复分配
+	mwsBuf        []Middleware
+	positions     []position
+	nextPositions []position
 }
 
 func (m *matchInfo) addValue(key string, value string) {
@@ -342,3 +717,12 @@ func (m *matchInfo) addValue(key string, value string) {
 	}
 	m.pathParams[key] = value
 }
+
+// reset 清空查找结果，但是保留 pathParams 和缓冲区的内存
+func (m *matchInfo) reset() {
+	m.n = nil
+	m.mws = nil
+	for k := range m.pathParams {
+		delete(m.pathParams, k)
+	}
+}
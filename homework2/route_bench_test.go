@@ -0,0 +1,398 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// legacyNode 是压缩之前按段划分的路由树，作为对照组
+// 用来保证 radix tree 的实现和原来的行为一致，以及做性能对比
+type legacyNode struct {
+	typ        nodeType
+	path       string
+	children   map[string]*legacyNode
+	handler    HandleFunc
+	starChild  *legacyNode
+	paramChild *legacyNode
+	paramName  string
+	regChild   *legacyNode
+	regExpr    *regexp.Regexp
+	mws        []Middleware
+	route      string
+}
+
+// newLegacyTrees 按照原来的算法注册路由，不做冲突检查
+func newLegacyTrees(routes []legacyRoute) map[string]*legacyNode {
+	trees := make(map[string]*legacyNode)
+	for _, rt := range routes {
+		root, ok := trees[rt.method]
+		if !ok {
+			root = &legacyNode{path: "/"}
+			trees[rt.method] = root
+		}
+		n := root
+		if rt.path != "/" {
+			for _, s := range strings.Split(rt.path[1:], "/") {
+				n = n.childOrCreate(s)
+			}
+		}
+		if rt.handler != nil {
+			n.handler = rt.handler
+			n.route = rt.path
+		}
+		n.mws = append(n.mws, rt.mws...)
+	}
+	return trees
+}
+
+type legacyRoute struct {
+	method  string
+	path    string
+	handler HandleFunc
+	mws     []Middleware
+}
+
+func (n *legacyNode) childOrCreate(path string) *legacyNode {
+	switch {
+	case path[0] == '*':
+		if n.starChild == nil {
+			n.starChild = &legacyNode{path: path, paramName: path[1:], typ: nodeTypeAny}
+		}
+		return n.starChild
+	case path[0] == ':':
+		paramName, expr, isReg := parseParam(path)
+		if isReg {
+			if n.regChild == nil {
+				n.regChild = &legacyNode{path: path, paramName: paramName,
+					regExpr: regexp.MustCompile(expr), typ: nodeTypeReg}
+			}
+			return n.regChild
+		}
+		if n.paramChild == nil {
+			n.paramChild = &legacyNode{path: path, paramName: paramName, typ: nodeTypeParam}
+		}
+		return n.paramChild
+	}
+	if n.children == nil {
+		n.children = make(map[string]*legacyNode)
+	}
+	child, ok := n.children[path]
+	if !ok {
+		child = &legacyNode{path: path, typ: nodeTypeStatic}
+		n.children[path] = child
+	}
+	return child
+}
+
+func (n *legacyNode) childOf(path string) (*legacyNode, bool) {
+	if res, ok := n.children[path]; ok {
+		return res, true
+	}
+	if n.regChild != nil && n.regChild.regExpr.MatchString(path) {
+		return n.regChild, true
+	}
+	if n.paramChild != nil {
+		return n.paramChild, true
+	}
+	return n.starChild, n.starChild != nil
+}
+
+// legacyMatch 对照组的查找结果
+type legacyMatch struct {
+	n          *legacyNode
+	pathParams map[string]string
+	mws        []Middleware
+}
+
+func legacyFindRoute(trees map[string]*legacyNode, method string, path string) (*legacyMatch, bool) {
+	root, ok := trees[method]
+	if !ok {
+		return nil, false
+	}
+
+	if path == "/" {
+		return &legacyMatch{n: root, mws: root.mws}, true
+	}
+
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	mi := &legacyMatch{pathParams: map[string]string{}}
+	current := root
+	for i, s := range segs {
+		var child *legacyNode
+		child, ok = current.childOf(s)
+		if !ok {
+			if current.typ == nodeTypeAny {
+				mi.n = current
+				mi.mws = legacyFindMdls(root, segs)
+				return mi, true
+			}
+			return nil, false
+		}
+		if child.typ == nodeTypeAny && child.paramName != "" {
+			mi.pathParams[child.paramName] = strings.Join(segs[i:], "/")
+			mi.n = child
+			mi.mws = legacyFindMdls(root, segs)
+			return mi, true
+		}
+		if child.paramName != "" {
+			mi.pathParams[child.paramName] = s
+		}
+		current = child
+	}
+	mi.n = current
+	mi.mws = legacyFindMdls(root, segs)
+	return mi, true
+}
+
+func legacyFindMdls(root *legacyNode, segs []string) []Middleware {
+	useMws := make([]Middleware, 0, 4)
+	nodePathList := make([]*legacyNode, 0, 4)
+	nodePathList = append(nodePathList, root)
+	if len(root.mws) > 0 {
+		useMws = append(useMws, root.mws...)
+	}
+	for _, seg := range segs {
+		matchChildrenNodes := make([]*legacyNode, 0, 4)
+		for _, n := range nodePathList {
+			if n.starChild != nil {
+				useMws = append(useMws, n.starChild.mws...)
+				matchChildrenNodes = append(matchChildrenNodes, n.starChild)
+			} else if n.regChild != nil {
+				if n.regChild.regExpr.Match([]byte(seg)) {
+					useMws = append(useMws, n.regChild.mws...)
+					matchChildrenNodes = append(matchChildrenNodes, n.regChild)
+				}
+			} else if n.paramChild != nil {
+				useMws = append(useMws, n.paramChild.mws...)
+				matchChildrenNodes = append(matchChildrenNodes, n.paramChild)
+			}
+			if v, ok := n.children[seg]; ok {
+				useMws = append(useMws, v.mws...)
+				matchChildrenNodes = append(matchChildrenNodes, v)
+			}
+		}
+		nodePathList = matchChildrenNodes
+	}
+	return useMws
+}
+
+// benchRoutes 和 Test_router_findRoute 里面注册的路由一样
+var benchRoutes = []struct {
+	method string
+	path   string
+}{
+	{method: http.MethodGet, path: "/"},
+	{method: http.MethodGet, path: "/user"},
+	{method: http.MethodPost, path: "/order/create"},
+	{method: http.MethodGet, path: "/user/*/home"},
+	{method: http.MethodPost, path: "/order/*"},
+	{method: http.MethodGet, path: "/a/b/*"},
+	{method: http.MethodGet, path: "/param/:id"},
+	{method: http.MethodGet, path: "/param/:id/detail"},
+	{method: http.MethodGet, path: "/param/:id/*"},
+	{method: http.MethodDelete, path: "/reg/:id(.*)"},
+	{method: http.MethodDelete, path: "/:id([0-9]+)/home"},
+}
+
+// benchRequests 和 Test_router_findRoute 里面的测试用例一样
+var benchRequests = []struct {
+	name   string
+	method string
+	path   string
+}{
+	{name: "method not found", method: http.MethodHead, path: "/"},
+	{name: "path not found", method: http.MethodGet, path: "/abc"},
+	{name: "root", method: http.MethodGet, path: "/"},
+	{name: "static", method: http.MethodGet, path: "/user"},
+	{name: "no handler", method: http.MethodPost, path: "/order"},
+	{name: "two layer", method: http.MethodPost, path: "/order/create"},
+	{name: "star", method: http.MethodPost, path: "/order/delete"},
+	{name: "star in middle", method: http.MethodGet, path: "/user/Tom/home"},
+	{name: "overflow", method: http.MethodPost, path: "/order/delete/123"},
+	{name: "multi star lvl 5", method: http.MethodGet, path: "/a/b/c/d/e/f"},
+	{name: "param", method: http.MethodGet, path: "/param/123"},
+	{name: "param star", method: http.MethodGet, path: "/param/123/abc"},
+	{name: "param static", method: http.MethodGet, path: "/param/123/detail"},
+	{name: "reg", method: http.MethodDelete, path: "/reg/123"},
+	{name: "reg home", method: http.MethodDelete, path: "/123/home"},
+	{name: "reg not match", method: http.MethodDelete, path: "/abc/home"},
+}
+
+// compressedRoutes 前缀重叠的路由，压缩之后一段路径的结尾会落在静态节点的中间
+var compressedRoutes = []struct {
+	method string
+	path   string
+}{
+	{method: http.MethodGet, path: "/user/home"},
+	{method: http.MethodGet, path: "/users"},
+	{method: http.MethodGet, path: "/userx/:id"},
+	{method: http.MethodGet, path: "/us/*"},
+	{method: http.MethodGet, path: "/users/:id/profile"},
+	{method: http.MethodGet, path: "/user/homepage/*name"},
+}
+
+var compressedRequests = []struct {
+	name   string
+	method string
+	path   string
+}{
+	{name: "prefix of static", method: http.MethodGet, path: "/use"},
+	{name: "split point", method: http.MethodGet, path: "/user"},
+	{name: "static sibling", method: http.MethodGet, path: "/users"},
+	{name: "static child", method: http.MethodGet, path: "/user/home"},
+	{name: "param after split", method: http.MethodGet, path: "/userx/123"},
+	{name: "param fallback", method: http.MethodGet, path: "/users/123/profile"},
+	{name: "prefix of static segment", method: http.MethodGet, path: "/user/hom/home"},
+	{name: "param without handler", method: http.MethodGet, path: "/users/123"},
+	{name: "star", method: http.MethodGet, path: "/us/a/b"},
+	{name: "named star", method: http.MethodGet, path: "/user/homepage/a/b"},
+	{name: "not found", method: http.MethodGet, path: "/usera"},
+}
+
+func newBenchRouter(withMws bool) (router, map[string]*legacyNode) {
+	r := newRouter()
+	var routes []legacyRoute
+	mockHandler := func(ctx *Context) {}
+	for _, br := range benchRoutes {
+		r.addRoute(br.method, br.path, mockHandler)
+		routes = append(routes, legacyRoute{method: br.method, path: br.path, handler: mockHandler})
+	}
+	for _, br := range compressedRoutes {
+		r.addRoute(br.method, br.path, mockHandler)
+		routes = append(routes, legacyRoute{method: br.method, path: br.path, handler: mockHandler})
+	}
+	if withMws {
+		mw := func(next HandleFunc) HandleFunc { return next }
+		for _, path := range []string{"/", "/param/:id", "/user", "/users/:id"} {
+			r.addRoute(http.MethodGet, path, nil, mw)
+			routes = append(routes, legacyRoute{method: http.MethodGet, path: path, mws: []Middleware{mw}})
+		}
+		r.addRoute(http.MethodPost, "/order/*", nil, mw)
+		routes = append(routes, legacyRoute{method: http.MethodPost, path: "/order/*", mws: []Middleware{mw}})
+	}
+	return r, newLegacyTrees(routes)
+}
+
+func Test_router_findRoute_Parity(t *testing.T) {
+	requests := append(benchRequests[:len(benchRequests):len(benchRequests)], compressedRequests...)
+	for _, withMws := range []bool{false, true} {
+		r, legacy := newBenchRouter(withMws)
+		// 复用同一个 matchInfo，确保复用不会影响结果
+		mi := &matchInfo{}
+		for _, br := range requests {
+			t.Run(br.name, func(t *testing.T) {
+				want, wantOk := legacyFindRoute(legacy, br.method, br.path)
+				mi.reset()
+				ok := r.findRouteInto(br.method, br.path, mi)
+				assert.Equal(t, wantOk, ok)
+				if !ok {
+					return
+				}
+				assert.Equal(t, want.n.route, mi.n.route)
+				assert.Equal(t, want.n.handler != nil, mi.n.handler != nil)
+				assert.Equal(t, len(want.pathParams), len(mi.pathParams))
+				for k, v := range want.pathParams {
+					assert.Equal(t, v, mi.pathParams[k])
+				}
+				assert.Equal(t, len(want.mws), len(mi.mws))
+			})
+		}
+	}
+}
+
+func Test_router_findRouteInto_Allocs(t *testing.T) {
+	r, _ := newBenchRouter(true)
+	mi := &matchInfo{}
+	for _, path := range []string{"/user", "/param/123", "/param/123/detail", "/"} {
+		// 先执行一次，让缓冲区和 pathParams 分配好
+		mi.reset()
+		r.findRouteInto(http.MethodGet, path, mi)
+		allocs := testing.AllocsPerRun(100, func() {
+			mi.reset()
+			r.findRouteInto(http.MethodGet, path, mi)
+		})
+		assert.Equal(t, float64(0), allocs, path)
+	}
+}
+
+func Test_HTTPServer_ServeHTTP_Allocs(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/user/:id", func(ctx *Context) {})
+	req := httptest.NewRequest(http.MethodGet, "/user/123", nil)
+	w := &discardWriter{header: http.Header{}}
+	s.ServeHTTP(w, req)
+	allocs := testing.AllocsPerRun(100, func() {
+		s.ServeHTTP(w, req)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkRouter_findRoute(b *testing.B) {
+	for _, withMws := range []bool{false, true} {
+		name := "no middleware"
+		if withMws {
+			name = "with middleware"
+		}
+		r, legacy := newBenchRouter(withMws)
+		b.Run(name, func(b *testing.B) {
+			b.Run("legacy", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for _, br := range benchRequests {
+						legacyFindRoute(legacy, br.method, br.path)
+					}
+				}
+			})
+			b.Run("current", func(b *testing.B) {
+				b.ReportAllocs()
+				mi := &matchInfo{}
+				for i := 0; i < b.N; i++ {
+					for _, br := range benchRequests {
+						mi.reset()
+						r.findRouteInto(br.method, br.path, mi)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkHTTPServer_ServeHTTP(b *testing.B) {
+	s := NewHTTPServer()
+	mockHandler := func(ctx *Context) {}
+	for _, br := range benchRoutes {
+		s.addRoute(br.method, br.path, mockHandler)
+	}
+	w := &discardWriter{header: http.Header{}}
+	reqs := make([]*http.Request, 0, len(benchRequests))
+	for _, br := range benchRequests {
+		reqs = append(reqs, httptest.NewRequest(br.method, br.path, nil))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, req := range reqs {
+			s.ServeHTTP(w, req)
+		}
+	}
+}
+
+// discardWriter 不会分配内存的 http.ResponseWriter
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(bs []byte) (int, error) {
+	return len(bs), nil
+}
+
+func (d *discardWriter) WriteHeader(statusCode int) {}
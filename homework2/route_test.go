@@ -93,111 +93,124 @@ func Test_router_AddRoute(t *testing.T) {
 		r.addRoute(tr.method, tr.path, mockHandler)
 	}
 
+	// 压缩之后的路由树，非静态节点挂在以 / 结尾的静态节点下面
 	wantRouter := &router{
 		trees: map[string]*node{
 			http.MethodGet: {
-				path: "/",
-				children: map[string]*node{
-					"user": {
-						path: "user",
-						children: map[string]*node{
-							"home": {path: "home", handler: mockHandler, typ: nodeTypeStatic},
-						},
-						handler: mockHandler,
-						typ:     nodeTypeStatic,
-					},
-					"order": {
-						path: "order",
-						children: map[string]*node{
-							"detail": {path: "detail", handler: mockHandler, typ: nodeTypeStatic},
-						},
-						starChild: &node{path: "*", handler: mockHandler, typ: nodeTypeAny},
-						typ:       nodeTypeStatic,
-					},
-					"param": {
-						path: "param",
-						paramChild: &node{
-							path:      ":id",
-							paramName: "id",
-							starChild: &node{
-								path:    "*",
-								handler: mockHandler,
-								typ:     nodeTypeAny,
+				path: "",
+				children: []*node{
+					{
+						path: "/",
+						children: []*node{
+							{
+								path:     "user",
+								children: []*node{{path: "/home", handler: mockHandler, typ: nodeTypeStatic}},
+								handler:  mockHandler,
+								typ:      nodeTypeStatic,
 							},
-							children: map[string]*node{"detail": {path: "detail", handler: mockHandler, typ: nodeTypeStatic}},
-							handler:  mockHandler,
-							typ:      nodeTypeParam,
-						},
-					},
-					"a": {
-						path: "a",
-						children: map[string]*node{
-							"b": {
-								path: "b",
-								starChild: &node{
-									path:    "*",
+							{
+								path:      "order/",
+								children:  []*node{{path: "detail", handler: mockHandler, typ: nodeTypeStatic}},
+								starChild: &node{path: "*", handler: mockHandler, typ: nodeTypeAny},
+								typ:       nodeTypeStatic,
+							},
+							{
+								path:      "a/b/",
+								starChild: &node{path: "*", handler: mockHandler, typ: nodeTypeAny},
+								typ:       nodeTypeStatic,
+							},
+							{
+								path: "param/",
+								paramChild: &node{
+									path:      ":id",
+									paramName: "id",
+									children: []*node{
+										{
+											path:      "/",
+											children:  []*node{{path: "detail", handler: mockHandler, typ: nodeTypeStatic}},
+											starChild: &node{path: "*", handler: mockHandler, typ: nodeTypeAny},
+											typ:       nodeTypeStatic,
+										},
+									},
 									handler: mockHandler,
-									typ:     nodeTypeAny,
+									typ:     nodeTypeParam,
 								},
-								//handler: mockHandler,
 								typ: nodeTypeStatic,
 							},
 						},
-						typ: nodeTypeStatic,
-					},
-				},
-				starChild: &node{
-					path: "*",
-					children: map[string]*node{
-						"abc": {
-							path:      "abc",
-							starChild: &node{path: "*", handler: mockHandler, typ: nodeTypeAny},
-							handler:   mockHandler,
-							typ:       nodeTypeStatic,
+						starChild: &node{
+							path: "*",
+							children: []*node{
+								{
+									path: "/",
+									children: []*node{
+										{
+											path: "abc",
+											children: []*node{
+												{
+													path:      "/",
+													starChild: &node{path: "*", handler: mockHandler, typ: nodeTypeAny},
+													typ:       nodeTypeStatic,
+												},
+											},
+											handler: mockHandler,
+											typ:     nodeTypeStatic,
+										},
+									},
+									starChild: &node{path: "*", handler: mockHandler, typ: nodeTypeAny},
+									typ:       nodeTypeStatic,
+								},
+							},
+							handler: mockHandler,
+							typ:     nodeTypeAny,
 						},
+						typ: nodeTypeStatic,
 					},
-					starChild: &node{path: "*", handler: mockHandler, typ: nodeTypeAny},
-					handler:   mockHandler,
-					typ:       nodeTypeAny,
 				},
 				handler: mockHandler,
 				typ:     nodeTypeStatic,
 			},
 			http.MethodPost: {
-				path: "/",
-				children: map[string]*node{
-					"order": {path: "order", children: map[string]*node{
-						"create": {path: "create", handler: mockHandler, typ: nodeTypeStatic},
-					}},
-					"login": {path: "login", handler: mockHandler, typ: nodeTypeStatic},
+				path: "",
+				children: []*node{
+					{
+						path: "/",
+						children: []*node{
+							{path: "order/create", handler: mockHandler, typ: nodeTypeStatic},
+							{path: "login", handler: mockHandler, typ: nodeTypeStatic},
+						},
+						typ: nodeTypeStatic,
+					},
 				},
 				typ: nodeTypeStatic,
 			},
 			http.MethodDelete: {
-				path: "/",
-				children: map[string]*node{
-					"reg": {
-						path: "reg",
-						typ:  nodeTypeStatic,
+				path: "",
+				children: []*node{
+					{
+						path: "/",
+						children: []*node{
+							{
+								path: "reg/",
+								typ:  nodeTypeStatic,
+								regChild: &node{
+									path:      ":id(.*)",
+									paramName: "id",
+									typ:       nodeTypeReg,
+									handler:   mockHandler,
+								},
+							},
+						},
 						regChild: &node{
-							path:      ":id(.*)",
-							paramName: "id",
+							path:      ":name(^.+$)",
+							paramName: "name",
 							typ:       nodeTypeReg,
-							handler:   mockHandler,
-						},
-					},
-				},
-				regChild: &node{
-					path:      ":name(^.+$)",
-					paramName: "name",
-					typ:       nodeTypeReg,
-					children: map[string]*node{
-						"abc": {
-							path:    "abc",
-							handler: mockHandler,
+							children:  []*node{{path: "/abc", handler: mockHandler, typ: nodeTypeStatic}},
 						},
+						typ: nodeTypeStatic,
 					},
 				},
+				typ: nodeTypeStatic,
 			},
 		},
 	}
@@ -330,34 +343,40 @@ func (n *node) equal(y *node) (string, bool) {
 	if len(n.children) != len(y.children) {
 		return fmt.Sprintf("%s 子节点长度不等", n.path), false
 	}
-	if len(n.children) == 0 {
-		return "", true
-	}
 
+	if (n.starChild == nil) != (y.starChild == nil) {
+		return fmt.Sprintf("%s 通配符节点不匹配", n.path), false
+	}
 	if n.starChild != nil {
 		str, ok := n.starChild.equal(y.starChild)
 		if !ok {
 			return fmt.Sprintf("%s 通配符节点不匹配 %s", n.path, str), false
 		}
 	}
+	if (n.paramChild == nil) != (y.paramChild == nil) {
+		return fmt.Sprintf("%s 路径参数节点不匹配", n.path), false
+	}
 	if n.paramChild != nil {
 		str, ok := n.paramChild.equal(y.paramChild)
 		if !ok {
 			return fmt.Sprintf("%s 路径参数节点不匹配 %s", n.path, str), false
 		}
 	}
-
+	if (n.regChild == nil) != (y.regChild == nil) {
+		return fmt.Sprintf("%s 正则节点不匹配", n.path), false
+	}
 	if n.regChild != nil {
 		str, ok := n.regChild.equal(y.regChild)
 		if !ok {
-			return fmt.Sprintf("%s 路径参数节点不匹配 %s", n.path, str), false
+			return fmt.Sprintf("%s 正则节点不匹配 %s", n.path, str), false
 		}
 	}
 
-	for k, v := range n.children {
-		yv, ok := y.children[k]
-		if !ok {
-			return fmt.Sprintf("%s 目标节点缺少子节点 %s", n.path, k), false
+	// 静态子节点按照第一个字节对应，不关心顺序
+	for _, v := range n.children {
+		yv := y.staticChild(v.path[0])
+		if yv == nil {
+			return fmt.Sprintf("%s 目标节点缺少子节点 %s", n.path, v.path), false
 		}
 		str, ok := v.equal(yv)
 		if !ok {
@@ -783,3 +802,145 @@ func Test_findRoute_Middleware(t *testing.T) {
 	}
 
 }
+
+func Test_router_findRoute_Params(t *testing.T) {
+	testRoutes := []string{
+		"/static/*filepath",
+		"/static/css/main.css",
+		"/user/:id<int>",
+		"/user/:id<int>/order/:oid<uuid>",
+		"/name/:name<alpha>",
+		"/article/:id?",
+		"/files/:dir/*path",
+	}
+
+	mockHandler := func(ctx *Context) {}
+	r := newRouter()
+	for _, path := range testRoutes {
+		r.addRoute(http.MethodGet, path, mockHandler)
+	}
+
+	testCases := []struct {
+		name      string
+		path      string
+		found     bool
+		wantRoute string
+		wantParam map[string]string
+	}{
+		{
+			name:      "catch all",
+			path:      "/static/js/lib/index.js",
+			found:     true,
+			wantRoute: "/static/*filepath",
+			wantParam: map[string]string{"filepath": "js/lib/index.js"},
+		},
+		{
+			name:      "catch all one segment",
+			path:      "/static/index.js",
+			found:     true,
+			wantRoute: "/static/*filepath",
+			wantParam: map[string]string{"filepath": "index.js"},
+		},
+		{
+			name:      "static first",
+			path:      "/static/css/main.css",
+			found:     true,
+			wantRoute: "/static/css/main.css",
+		},
+		{
+			name:      "catch all with param",
+			path:      "/files/a/b/c",
+			found:     true,
+			wantRoute: "/files/:dir/*path",
+			wantParam: map[string]string{"dir": "a", "path": "b/c"},
+		},
+		{
+			name:      "int",
+			path:      "/user/-123",
+			found:     true,
+			wantRoute: "/user/:id<int>",
+			wantParam: map[string]string{"id": "-123"},
+		},
+		{
+			name: "not int",
+			path: "/user/abc123",
+		},
+		{
+			name:      "uuid",
+			path:      "/user/123/order/123e4567-e89b-12d3-a456-426614174000",
+			found:     true,
+			wantRoute: "/user/:id<int>/order/:oid<uuid>",
+			wantParam: map[string]string{"id": "123", "oid": "123e4567-e89b-12d3-a456-426614174000"},
+		},
+		{
+			name: "not uuid",
+			path: "/user/123/order/123",
+		},
+		{
+			name:      "alpha",
+			path:      "/name/Tom",
+			found:     true,
+			wantRoute: "/name/:name<alpha>",
+			wantParam: map[string]string{"name": "Tom"},
+		},
+		{
+			name:      "optional without param",
+			path:      "/article",
+			found:     true,
+			wantRoute: "/article/:id?",
+		},
+		{
+			name:      "optional with param",
+			path:      "/article/123",
+			found:     true,
+			wantRoute: "/article/:id?",
+			wantParam: map[string]string{"id": "123"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, found := r.findHandler(http.MethodGet, tc.path)
+			assert.Equal(t, tc.found, found)
+			if !found {
+				return
+			}
+			assert.Equal(t, tc.wantRoute, mi.n.route)
+			assert.Equal(t, tc.wantParam, mi.pathParams)
+		})
+	}
+}
+
+func Test_router_AddRoute_ParamsPanic(t *testing.T) {
+	mockHandler := func(ctx *Context) {}
+	r := newRouter()
+	assert.PanicsWithValue(t, "web: 非法路由，命名通配符只能是最后一段 [/a/*path/b]", func() {
+		r.addRoute(http.MethodGet, "/a/*path/b", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 路由冲突，通配符路由冲突，已有 *path，新注册 *file", func() {
+		r.addRoute(http.MethodGet, "/b/*path", mockHandler)
+		r.addRoute(http.MethodGet, "/b/*file", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 路由冲突，通配符路由冲突，已有 *path，新注册 *", func() {
+		r.addRoute(http.MethodGet, "/b/*", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 非法路由，已有通配符路由。不允许同时注册通配符路由和参数路由 [:id]", func() {
+		r.addRoute(http.MethodGet, "/b/:id", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 非法路由，已有通配符路由。不允许同时注册通配符路由和正则路由 [:id<int>]", func() {
+		r.addRoute(http.MethodGet, "/b/:id<int>", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 非法路由，不支持的参数类型 float", func() {
+		r.addRoute(http.MethodGet, "/c/:id<float>", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 路由冲突，正则路由冲突，已有 :id<int>，新注册 :id<uint>", func() {
+		r.addRoute(http.MethodGet, "/d/:id<int>", mockHandler)
+		r.addRoute(http.MethodGet, "/d/:id<uint>", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 非法路由，只有最后一段路径参数可以是可选的 [/e/abc?]", func() {
+		r.addRoute(http.MethodGet, "/e/abc?", mockHandler)
+	})
+	assert.PanicsWithValue(t, "web: 路由冲突[/f/:id?]", func() {
+		r.addRoute(http.MethodGet, "/f", mockHandler)
+		r.addRoute(http.MethodGet, "/f/:id?", mockHandler)
+	})
+}
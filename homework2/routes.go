@@ -0,0 +1,153 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var errRouteNotFound = errors.New("web: 命名路由不存在")
+
+// Route 注册路由之后返回，用于设置路由的其它属性
+type Route struct {
+	r     *router
+	route string
+}
+
+// Name 给路由命名，之后可以通过 URLFor 生成 URL
+// 同一个名字只能使用一次
+func (r *Route) Name(name string) *Route {
+	if old, ok := r.r.names[name]; ok {
+		panic(fmt.Sprintf("web: 路由名字冲突 %s，已有 %s，新注册 %s", name, old, r.route))
+	}
+	r.r.names[name] = r.route
+	return r
+}
+
+// RouteInfo 路由的描述信息
+type RouteInfo struct {
+	Method  string
+	Pattern string
+	// Name 命名路由的名字
+	Name string
+	// HandlerName handler 的函数名字
+	HandlerName string
+	// MiddlewareCount 直接注册在这个路由上的 middleware 的数量，
+	// 不包括祖先节点和通配符节点上的 middleware
+	MiddlewareCount int
+}
+
+// Routes 返回所有注册了 handler 的路由，按照方法和路由排序
+func (r *router) Routes() []RouteInfo {
+	names := make(map[string]string, len(r.names))
+	for name, route := range r.names {
+		names[route] = name
+	}
+	res := make([]RouteInfo, 0, 16)
+	for method, root := range r.trees {
+		// 可选参数的路由会对应两个节点，只需要返回一次
+		seen := make(map[string]struct{}, 16)
+		root.walk(func(n *node) {
+			if n.handler == nil {
+				return
+			}
+			if _, ok := seen[n.route]; ok {
+				return
+			}
+			seen[n.route] = struct{}{}
+			res = append(res, RouteInfo{
+				Method:          method,
+				Pattern:         n.route,
+				Name:            names[n.route],
+				HandlerName:     runtime.FuncForPC(reflect.ValueOf(n.handler).Pointer()).Name(),
+				MiddlewareCount: len(n.mws),
+			})
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Method != res[j].Method {
+			return res[i].Method < res[j].Method
+		}
+		return res[i].Pattern < res[j].Pattern
+	})
+	return res
+}
+
+// walk 深度优先遍历
+func (n *node) walk(fn func(n *node)) {
+	fn(n)
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+	for _, child := range []*node{n.regChild, n.paramChild, n.starChild} {
+		if child != nil {
+			child.walk(fn)
+		}
+	}
+}
+
+// URLFor 根据命名路由生成 URL
+// params 是路径参数，正则路由和类型约束会被校验
+// 匿名通配符 * 使用 "*" 作为参数名字
+// 可选参数没有传的时候会被省略
+func (r *router) URLFor(name string, params map[string]string) (string, error) {
+	route, ok := r.names[name]
+	if !ok {
+		return "", fmt.Errorf("%w, name: %s", errRouteNotFound, name)
+	}
+	if route == "/" {
+		return route, nil
+	}
+	segs := strings.Split(route[1:], "/")
+	res := make([]string, 0, len(segs))
+	for _, seg := range segs {
+		switch seg[0] {
+		case ':':
+			optional := seg[len(seg)-1] == '?'
+			if optional {
+				seg = seg[:len(seg)-1]
+			}
+			paramName, expr, isReg := parseParam(seg)
+			val, ok := params[paramName]
+			if !ok {
+				if optional {
+					continue
+				}
+				return "", fmt.Errorf("web: 生成 URL 失败，缺少参数 %s", paramName)
+			}
+			if isReg {
+				// 和路由匹配的时候保持一致
+				matched, err := regexp.MatchString(expr, val)
+				if err != nil {
+					return "", err
+				}
+				if !matched {
+					return "", fmt.Errorf("web: 生成 URL 失败，参数 %s 的值 %s 不满足约束 %s", paramName, val, expr)
+				}
+			}
+			res = append(res, url.PathEscape(val))
+		case '*':
+			paramName := seg[1:]
+			if paramName == "" {
+				paramName = "*"
+			}
+			val, ok := params[paramName]
+			if !ok || val == "" {
+				return "", fmt.Errorf("web: 生成 URL 失败，缺少参数 %s", paramName)
+			}
+			parts := strings.Split(strings.Trim(val, "/"), "/")
+			for i, p := range parts {
+				parts[i] = url.PathEscape(p)
+			}
+			res = append(res, strings.Join(parts, "/"))
+		default:
+			res = append(res, seg)
+		}
+	}
+	return "/" + strings.Join(res, "/"), nil
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func userHandler(ctx *Context) {}
+
+func TestHTTPServer_Routes(t *testing.T) {
+	mw := func(next HandleFunc) HandleFunc { return next }
+	s := NewHTTPServer()
+	s.Get("/user/:id", userHandler).Name("user.show")
+	s.Post("/user", userHandler)
+	s.Get("/article/:id?", userHandler)
+	s.Use(http.MethodGet, "/user/:id", mw, mw)
+	s.Use(http.MethodGet, "/order", mw)
+
+	assert.Equal(t, []RouteInfo{
+		{
+			Method:      http.MethodGet,
+			Pattern:     "/article/:id?",
+			HandlerName: "myhomework/homework2.userHandler",
+		},
+		{
+			Method:          http.MethodGet,
+			Pattern:         "/user/:id",
+			Name:            "user.show",
+			HandlerName:     "myhomework/homework2.userHandler",
+			MiddlewareCount: 2,
+		},
+		{
+			Method:      http.MethodPost,
+			Pattern:     "/user",
+			HandlerName: "myhomework/homework2.userHandler",
+		},
+	}, s.Routes())
+
+	assert.PanicsWithValue(t, "web: 路由名字冲突 user.show，已有 /user/:id，新注册 /user", func() {
+		s.Post("/user/create", userHandler).Name("user.create")
+		s.Get("/user", userHandler).Name("user.show")
+	})
+}
+
+func TestHTTPServer_URLFor(t *testing.T) {
+	s := NewHTTPServer()
+	s.Get("/", userHandler).Name("home")
+	s.Get("/user/:id<int>", userHandler).Name("user.show")
+	s.Get("/member/:id/order/:oid([0-9]+)", userHandler).Name("user.order")
+	s.Get("/static/*filepath", userHandler).Name("static")
+	s.Get("/any/*", userHandler).Name("any")
+	s.Get("/article/:id?", userHandler).Name("article")
+
+	testCases := []struct {
+		name    string
+		route   string
+		params  map[string]string
+		wantURL string
+		wantErr string
+	}{
+		{
+			name:    "root",
+			route:   "home",
+			wantURL: "/",
+		},
+		{
+			name:    "param",
+			route:   "user.show",
+			params:  map[string]string{"id": "123"},
+			wantURL: "/user/123",
+		},
+		{
+			name:    "constraint",
+			route:   "user.show",
+			params:  map[string]string{"id": "abc"},
+			wantErr: "web: 生成 URL 失败，参数 id 的值 abc 不满足约束 ^(?:-?[0-9]+)$",
+		},
+		{
+			name:    "missing",
+			route:   "user.order",
+			params:  map[string]string{"id": "Tom"},
+			wantErr: "web: 生成 URL 失败，缺少参数 oid",
+		},
+		{
+			name:    "regex",
+			route:   "user.order",
+			params:  map[string]string{"id": "Tom Cat", "oid": "456"},
+			wantURL: "/member/Tom%20Cat/order/456",
+		},
+		{
+			name:    "catch all",
+			route:   "static",
+			params:  map[string]string{"filepath": "js/a b.js"},
+			wantURL: "/static/js/a%20b.js",
+		},
+		{
+			name:    "anonymous star",
+			route:   "any",
+			params:  map[string]string{"*": "abc"},
+			wantURL: "/any/abc",
+		},
+		{
+			name:    "optional",
+			route:   "article",
+			wantURL: "/article",
+		},
+		{
+			name:    "optional with param",
+			route:   "article",
+			params:  map[string]string{"id": "12"},
+			wantURL: "/article/12",
+		},
+		{
+			name:    "not found",
+			route:   "unknown",
+			wantErr: "web: 命名路由不存在, name: unknown",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := s.URLFor(tc.route, tc.params)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantURL, u)
+		})
+	}
+}
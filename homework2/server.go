@@ -1,6 +1,16 @@
 package web
 
-import "net/http"
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 type HandleFunc func(ctx *Context)
 
@@ -21,49 +31,435 @@ type Server interface {
 // 确保 HTTPServer 肯定实现了 Server 接口
 var _ Server = &HTTPServer{}
 
+type HTTPServerOption func(server *HTTPServer)
+
 type HTTPServer struct {
+	// inFlight 正在处理的请求数量，放在最前面保证 64 位对齐
+	inFlight int64
+	// rejected 关闭过程中拒绝掉的请求数量
+	rejected int64
+	// rejecting 不为 0 说明在关闭中，拒绝新请求
+	rejecting int32
+
 	router
+
+	// mws 是全局 middleware，对所有请求生效，包括 404
+	mws []Middleware
+
+	tplEngine TemplateEngine
+
+	// statusHandlers 按照响应码注册的处理函数，例如渲染 404 页面
+	statusHandlers map[int]HandleFunc
+
+	// redirectTrailingSlash 为 true 的时候，/user/ 会被重定向到 /user，而不是直接匹配
+	redirectTrailingSlash bool
+	// cleanPath 为 true 的时候，/a//b/../c 会被重定向到 /a/c
+	cleanPath bool
+	// caseInsensitive 为 true 的时候，/USER 会被重定向到注册的 /user
+	caseInsensitive bool
+
+	// handler 是组装好的全局 middleware 调用链，避免每个请求都重新组装
+	handler HandleFunc
+	// ctxPool 复用 Context，连同里面的路径参数和查找路由用的缓冲区
+	ctxPool sync.Pool
+
+	mutex sync.Mutex
+	// servers Start 和 Serve 创建的 http.Server，关闭的时候要一起关闭
+	servers []*http.Server
+	// listeners 和 conns 是 Handoff 用的，conns 记录连接和它的状态
+	listeners []net.Listener
+	conns     map[net.Conn]http.ConnState
+	closed    bool
 }
 
-func NewHTTPServer() *HTTPServer {
-	return &HTTPServer{
+func NewHTTPServer(opts ...HTTPServerOption) *HTTPServer {
+	res := &HTTPServer{
 		router: newRouter(),
 	}
+	res.ctxPool.New = func() any {
+		return &Context{}
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	res.handler = res.buildChain()
+	return res
+}
+
+// ServerWithMiddleware 注册全局 middleware
+func ServerWithMiddleware(mws ...Middleware) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.mws = append(server.mws, mws...)
+	}
+}
+
+// ServerWithStatusHandler 在响应码为 code 的时候执行 handler，例如渲染 404 或者 500 的页面
+// handler 在所有的 middleware 之后执行，所以 recovery 之类的 middleware 设置的响应码也会生效
+func ServerWithStatusHandler(code int, handler HandleFunc) HTTPServerOption {
+	return func(server *HTTPServer) {
+		if server.statusHandlers == nil {
+			server.statusHandlers = make(map[int]HandleFunc, 4)
+		}
+		server.statusHandlers[code] = handler
+	}
+}
+
+// ServerWithRedirectTrailingSlash 带了结尾 / 的请求重定向到没有 / 的路由
+// 默认情况下 /user/ 会直接命中 /user
+func ServerWithRedirectTrailingSlash() HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.redirectTrailingSlash = true
+	}
+}
+
+// ServerWithCleanPath 清理路径里面的 .. 和重复的 /，清理之后能命中就重定向过去
+func ServerWithCleanPath() HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.cleanPath = true
+	}
+}
+
+// ServerWithCaseInsensitive 没有命中的时候忽略大小写再查找一次，命中就重定向到注册时候的大小写
+func ServerWithCaseInsensitive() HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.caseInsensitive = true
+	}
 }
 
 // ServeHTTP HTTPServer 处理请求的入口
+// 执行顺序是：全局 middleware -> 路由上的 middleware -> handler，
+// 最后由 flushResp 统一把 RespStatusCode 和 RespData 写回去
+// 路由在执行全局 middleware 之前就已经查找好了，所以全局 middleware 也能拿到 MatchedRoute
+// Context 是复用的，请求处理完毕之后不能再使用，例如在另外的 goroutine 里面
 func (s *HTTPServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	ctx := &Context{
-		Req:  request,
-		Resp: writer,
+	// 先计数再检查，保证 Shutdown 看到计数为 0 的时候，不会再有请求开始执行
+	atomic.AddInt64(&s.inFlight, 1)
+	defer atomic.AddInt64(&s.inFlight, -1)
+	if atomic.LoadInt32(&s.rejecting) != 0 {
+		atomic.AddInt64(&s.rejected, 1)
+		writer.Header().Set("Connection", "close")
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = writer.Write([]byte("Service Unavailable"))
+		return
+	}
+	ctx := s.ctxPool.Get().(*Context)
+	ctx.reset(writer, request, s.tplEngine)
+	s.match(ctx)
+	s.handler(ctx)
+	s.ctxPool.Put(ctx)
+}
+
+// buildChain 组装全局 middleware
+func (s *HTTPServer) buildChain() HandleFunc {
+	root := s.serve
+	for i := len(s.mws) - 1; i >= 0; i-- {
+		root = s.mws[i](root)
+	}
+	// 刷新响应必须是最外层，这样所有的 middleware 都可以修改响应
+	var m Middleware = func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			if hdl, ok := s.statusHandlers[ctx.RespStatusCode]; ok {
+				hdl(ctx)
+			}
+			s.flushResp(ctx)
+		}
 	}
-	s.serve(ctx)
+	return m(root)
 }
 
-// Start 启动服务器
+// Start 启动服务器，会一直阻塞，直到出错或者 Shutdown 被调用
+// Shutdown 之后返回 http.ErrServerClosed
 func (s *HTTPServer) Start(addr string) error {
-	return http.ListenAndServe(addr, s)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在已有的 listener 上处理请求，用法和 Start 一样
+func (s *HTTPServer) Serve(l net.Listener) error {
+	srv := &http.Server{Handler: s, ConnState: s.trackConn}
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		_ = l.Close()
+		return http.ErrServerClosed
+	}
+	s.servers = append(s.servers, srv)
+	s.listeners = append(s.listeners, l)
+	s.mutex.Unlock()
+	err := srv.Serve(l)
+	// Handoff 直接关闭了 listener，和 Shutdown 一样返回 http.ErrServerClosed
+	if s.isClosed() {
+		return http.ErrServerClosed
+	}
+	return err
+}
+
+// RejectNew 拒绝新请求，返回 503 并且要求客户端关闭连接
+// 已经在处理的请求不受影响
+func (s *HTTPServer) RejectNew() {
+	atomic.StoreInt32(&s.rejecting, 1)
+}
+
+// InFlight 正在处理的请求数量
+func (s *HTTPServer) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// Rejected 关闭过程中拒绝掉的请求数量
+func (s *HTTPServer) Rejected() int64 {
+	return atomic.LoadInt64(&s.rejected)
+}
+
+// Handoff 零停机重启的时候使用，关闭监听，新的连接都由子进程接收，
+// 已经接收的连接处理完当前的请求就断开，不会拒绝请求。ctx 超时之后不再等待
+// 之后再调用 Shutdown 的时候，已经没有需要拒绝的请求了
+func (s *HTTPServer) Handoff(ctx context.Context) error {
+	s.mutex.Lock()
+	s.closed = true
+	servers, listeners := s.servers, s.listeners
+	s.listeners = nil
+	s.mutex.Unlock()
+	// 关闭空闲的连接，客户端重新连接的时候由子进程接收
+	for _, srv := range servers {
+		srv.SetKeepAlivesEnabled(false)
+	}
+	for _, l := range listeners {
+		if err := l.Close(); err != nil {
+			return err
+		}
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for s.busyConns() > 0 || s.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func (s *HTTPServer) trackConn(conn net.Conn, state http.ConnState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state == http.StateClosed || state == http.StateHijacked {
+		delete(s.conns, conn)
+		return
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]http.ConnState, 16)
+	}
+	s.conns[conn] = state
+}
+
+// busyConns 新建和活跃的连接数量，新建的连接可能已经发了请求，只是还没有读到
+func (s *HTTPServer) busyConns() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := 0
+	for _, state := range s.conns {
+		if state == http.StateNew || state == http.StateActive {
+			res++
+		}
+	}
+	return res
+}
+
+func (s *HTTPServer) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+// Shutdown 优雅退出：拒绝新请求，关闭监听，等待正在处理的请求结束
+// ctx 超时的时候返回 ctx.Err()，这时候可能还有请求没有处理完
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	s.RejectNew()
+	s.mutex.Lock()
+	s.closed = true
+	servers := s.servers
+	s.mutex.Unlock()
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	// 没有通过 Start 启动，而是挂在别的 http.Server 上的时候，只能自己等
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for s.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
 }
 
-func (s *HTTPServer) Post(path string, handler HandleFunc) {
+func (s *HTTPServer) Post(path string, handler HandleFunc) *Route {
 	s.addRoute(http.MethodPost, path, handler)
+	return &Route{r: &s.router, route: path}
 }
 
-func (s *HTTPServer) Get(path string, handler HandleFunc) {
+func (s *HTTPServer) Get(path string, handler HandleFunc) *Route {
 	s.addRoute(http.MethodGet, path, handler)
+	return &Route{r: &s.router, route: path}
+}
+
+// match 查找路由
+// - HEAD 请求没有注册的时候，使用 GET 的路由
+// - 路径存在但是方法不对的时候，记录下允许的方法，用于返回 405 或者响应 OPTIONS
+// - 开启了路径修正的时候，能够命中修正之后的路径就重定向
+func (s *HTTPServer) match(ctx *Context) {
+	method, path := ctx.Req.Method, ctx.Req.URL.Path
+
+	target := s.canonicalPath(path)
+	if target != path && len(s.allowedMethods(target)) > 0 {
+		ctx.redirectTo = target
+		return
+	}
+
+	strict := s.redirectTrailingSlash && len(path) > 1 && path[len(path)-1] == '/'
+	if !strict {
+		mi := &ctx.matched
+		ok := s.findHandlerInto(method, path, mi)
+		if !ok && method == http.MethodHead {
+			ok = s.findHandlerInto(http.MethodGet, path, mi)
+		}
+		if ok {
+			ctx.PathParams = mi.pathParams
+			ctx.MatchedRoute = mi.n.route
+			ctx.mi = mi
+			return
+		}
+	}
+
+	if s.caseInsensitive {
+		lookup := method
+		if _, ok := s.trees[lookup]; !ok && method == http.MethodHead {
+			lookup = http.MethodGet
+		}
+		if fixed, ok := s.findCaseInsensitive(lookup, target); ok && fixed != path {
+			ctx.redirectTo = fixed
+			return
+		}
+	}
+
+	if !strict {
+		ctx.allowedMethods = s.allowedMethods(path)
+	}
+}
+
+// canonicalPath 按照开启的选项修正路径
+func (s *HTTPServer) canonicalPath(p string) string {
+	if s.cleanPath {
+		p = cleanPath(p)
+	}
+	if s.redirectTrailingSlash && len(p) > 1 {
+		p = strings.TrimRight(p, "/")
+		if p == "" {
+			p = "/"
+		}
+		p = trimLeadingSlashes(p)
+	}
+	return p
+}
+
+// trimLeadingSlashes 把开头的多个 / 合并成一个
+// 浏览器会把 //evil.com 和 /\evil.com 当成另一个域名，重定向到这种地址就是开放重定向
+func trimLeadingSlashes(p string) string {
+	if len(p) > 1 && (p[1] == '/' || p[1] == '\\') {
+		return "/" + strings.TrimLeft(p, "/\\")
+	}
+	return p
+}
+
+// cleanPath 去除 .. 和重复的 /，但是保留结尾的 /
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+// redirect 重定向到修正之后的路径，保留查询参数
+// GET 和 HEAD 使用 301，其它方法使用 308，保证浏览器重定向之后不会修改方法和请求体
+func (s *HTTPServer) redirect(ctx *Context) {
+	location := trimLeadingSlashes(ctx.redirectTo)
+	if ctx.Req.URL.RawQuery != "" {
+		location += "?" + ctx.Req.URL.RawQuery
+	}
+	code := http.StatusPermanentRedirect
+	if ctx.Req.Method == http.MethodGet || ctx.Req.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	_ = ctx.Redirect(code, location)
 }
 
 func (s *HTTPServer) serve(ctx *Context) {
-	mi, ok := s.findRoute(ctx.Req.Method, ctx.Req.URL.Path)
-	if !ok || mi.n == nil || mi.n.handler == nil {
-		ctx.Resp.WriteHeader(404)
-		ctx.Resp.Write([]byte("Not Found"))
+	mi := ctx.mi
+	if mi == nil {
+		if ctx.redirectTo != "" {
+			s.redirect(ctx)
+			return
+		}
+		if len(ctx.allowedMethods) == 0 {
+			ctx.RespStatusCode = http.StatusNotFound
+			ctx.RespData = []byte("Not Found")
+			return
+		}
+		ctx.Resp.Header().Set("Allow", strings.Join(ctx.allowedMethods, ", "))
+		if ctx.Req.Method == http.MethodOptions {
+			ctx.RespStatusCode = http.StatusNoContent
+			return
+		}
+		ctx.RespStatusCode = http.StatusMethodNotAllowed
+		ctx.RespData = []byte("Method Not Allowed")
 		return
 	}
-	ctx.PathParams = mi.pathParams
-	mi.n.handler(ctx)
+	root := mi.n.handler
+	// 按照 findMdls 返回的顺序组装，先找到的在外层
+	for i := len(mi.mws) - 1; i >= 0; i-- {
+		root = mi.mws[i](root)
+	}
+	root(ctx)
 }
 
+// flushResp 把 middleware 和 handler 设置的响应写回去
+func (s *HTTPServer) flushResp(ctx *Context) {
+	if ctx.RespStatusCode != 0 {
+		ctx.Resp.WriteHeader(ctx.RespStatusCode)
+	}
+	// HEAD 请求不需要响应体
+	if len(ctx.RespData) > 0 && ctx.Req.Method != http.MethodHead {
+		_, err := ctx.Resp.Write(ctx.RespData)
+		if err != nil {
+			log.Println("web: 写入响应失败", err)
+		}
+	}
+}
+
+// Use 在某个路由上注册 middleware，会作用于它和它所有的子路由
 func (s *HTTPServer) Use(method string, path string, mws ...Middleware) {
 	s.addRoute(method, path, nil, mws...)
 }
+
+// UseAll 注册全局 middleware，和 ServerWithMiddleware 的效果一样
+// 必须在启动服务器之前调用
+func (s *HTTPServer) UseAll(mws ...Middleware) {
+	s.mws = append(s.mws, mws...)
+	s.handler = s.buildChain()
+}
@@ -1 +1,467 @@
 package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServer_ServeHTTP_Middleware(t *testing.T) {
+	var mdlBuilder = func(i byte) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.RespData = append(ctx.RespData, i)
+				next(ctx)
+			}
+		}
+	}
+
+	s := NewHTTPServer(ServerWithMiddleware(mdlBuilder('g')))
+	s.UseAll(mdlBuilder('h'))
+	s.Use(http.MethodGet, "/", mdlBuilder('/'))
+	s.Use(http.MethodGet, "/a", mdlBuilder('a'))
+	s.Get("/a/b", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = append(ctx.RespData, "handler"...)
+	})
+	// 先注册路由再注册 middleware 不应该冲突
+	s.Use(http.MethodGet, "/a/b", mdlBuilder('b'))
+	s.Get("/a/:id", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusAccepted
+		ctx.RespData = append(ctx.RespData, ctx.PathParams["id"]...)
+	})
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantResp string
+	}{
+		{
+			name:     "static",
+			path:     "/a/b",
+			wantCode: http.StatusOK,
+			wantResp: "gh/abhandler",
+		},
+		{
+			name:     "param",
+			path:     "/a/c",
+			wantCode: http.StatusAccepted,
+			wantResp: "gh/ac",
+		},
+		{
+			name:     "not found",
+			path:     "/b",
+			wantCode: http.StatusNotFound,
+			wantResp: "Not Found",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+}
+
+func TestHTTPServer_ServeHTTP_Status(t *testing.T) {
+	s := NewHTTPServer(ServerWithStatusHandler(http.StatusNotFound, func(ctx *Context) {
+		ctx.RespData = []byte("<h1>404</h1>")
+	}), ServerWithStatusHandler(http.StatusInternalServerError, func(ctx *Context) {
+		ctx.RespData = []byte("<h1>500</h1>")
+	}))
+	s.Get("/user", func(ctx *Context) {
+		_ = ctx.RespString(http.StatusOK, "get user")
+	})
+	s.Post("/user", func(ctx *Context) {
+		_ = ctx.RespString(http.StatusOK, "post user")
+	})
+	s.Post("/order", func(ctx *Context) {
+		_ = ctx.RespString(http.StatusOK, "post order")
+	})
+	s.Get("/error", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusInternalServerError
+	})
+	// middleware 在 handler 之后替换响应
+	s.Use(http.MethodGet, "/replace", func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			ctx.RespData = []byte("replaced")
+		}
+	})
+	s.Get("/replace", func(ctx *Context) {
+		_ = ctx.RespString(http.StatusOK, "origin")
+	})
+
+	testCases := []struct {
+		name      string
+		method    string
+		path      string
+		wantCode  int
+		wantAllow string
+		wantResp  string
+	}{
+		{
+			name:     "not found page",
+			method:   http.MethodGet,
+			path:     "/abc",
+			wantCode: http.StatusNotFound,
+			wantResp: "<h1>404</h1>",
+		},
+		{
+			name:      "method not allowed",
+			method:    http.MethodDelete,
+			path:      "/user",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantAllow: "GET, HEAD, OPTIONS, POST",
+			wantResp:  "Method Not Allowed",
+		},
+		{
+			name:      "method not allowed without get",
+			method:    http.MethodGet,
+			path:      "/order",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantAllow: "OPTIONS, POST",
+			wantResp:  "Method Not Allowed",
+		},
+		{
+			name:      "options",
+			method:    http.MethodOptions,
+			path:      "/user",
+			wantCode:  http.StatusNoContent,
+			wantAllow: "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			name:     "options not found",
+			method:   http.MethodOptions,
+			path:     "/abc",
+			wantCode: http.StatusNotFound,
+			wantResp: "<h1>404</h1>",
+		},
+		{
+			name:     "head",
+			method:   http.MethodHead,
+			path:     "/user",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "error page",
+			method:   http.MethodGet,
+			path:     "/error",
+			wantCode: http.StatusInternalServerError,
+			wantResp: "<h1>500</h1>",
+		},
+		{
+			name:     "replace",
+			method:   http.MethodGet,
+			path:     "/replace",
+			wantCode: http.StatusOK,
+			wantResp: "replaced",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantAllow, recorder.Header().Get("Allow"))
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+}
+
+func TestHTTPServer_ServeHTTP_Redirect(t *testing.T) {
+	handler := func(ctx *Context) {
+		_ = ctx.RespString(http.StatusOK, ctx.MatchedRoute)
+	}
+	register := func(s *HTTPServer) *HTTPServer {
+		s.Get("/user/home", handler)
+		s.Get("/user/:id/Detail", handler)
+		s.Post("/order", handler)
+		return s
+	}
+	// 参数路径能匹配任意的一段，用来检查会不会重定向到别的域名
+	registerParam := func(s *HTTPServer) *HTTPServer {
+		s.Get("/:id", handler)
+		return register(s)
+	}
+
+	testCases := []struct {
+		name         string
+		server       *HTTPServer
+		method       string
+		path         string
+		wantCode     int
+		wantLocation string
+		wantResp     string
+	}{
+		{
+			name:     "default trailing slash",
+			server:   register(NewHTTPServer()),
+			method:   http.MethodGet,
+			path:     "/user/home/",
+			wantCode: http.StatusOK,
+			wantResp: "/user/home",
+		},
+		{
+			name:         "redirect trailing slash",
+			server:       register(NewHTTPServer(ServerWithRedirectTrailingSlash())),
+			method:       http.MethodGet,
+			path:         "/user/home/?a=b",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user/home?a=b",
+		},
+		{
+			name:         "redirect trailing slash post",
+			server:       register(NewHTTPServer(ServerWithRedirectTrailingSlash())),
+			method:       http.MethodPost,
+			path:         "/order/",
+			wantCode:     http.StatusPermanentRedirect,
+			wantLocation: "/order",
+		},
+		{
+			name:     "trailing slash not found",
+			server:   register(NewHTTPServer(ServerWithRedirectTrailingSlash())),
+			method:   http.MethodGet,
+			path:     "/abc/",
+			wantCode: http.StatusNotFound,
+			wantResp: "Not Found",
+		},
+		{
+			name:         "clean path",
+			server:       register(NewHTTPServer(ServerWithCleanPath())),
+			method:       http.MethodGet,
+			path:         "//user/abc/../home",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user/home",
+		},
+		{
+			name:         "clean path and trailing slash",
+			server:       register(NewHTTPServer(ServerWithCleanPath(), ServerWithRedirectTrailingSlash())),
+			method:       http.MethodGet,
+			path:         "/user//home/",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user/home",
+		},
+		{
+			name:         "no open redirect",
+			server:       registerParam(NewHTTPServer(ServerWithRedirectTrailingSlash())),
+			method:       http.MethodGet,
+			path:         "//evil.com/",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/evil.com",
+		},
+		{
+			name:         "no open redirect backslash",
+			server:       registerParam(NewHTTPServer(ServerWithRedirectTrailingSlash())),
+			method:       http.MethodGet,
+			path:         "/%5C%5Cevil.com/",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/evil.com",
+		},
+		{
+			name:         "no open redirect clean path",
+			server:       registerParam(NewHTTPServer(ServerWithCleanPath(), ServerWithRedirectTrailingSlash())),
+			method:       http.MethodGet,
+			path:         "///evil.com/",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/evil.com",
+		},
+		{
+			name:     "case sensitive",
+			server:   register(NewHTTPServer()),
+			method:   http.MethodGet,
+			path:     "/USER/home",
+			wantCode: http.StatusNotFound,
+			wantResp: "Not Found",
+		},
+		{
+			name:         "case insensitive",
+			server:       register(NewHTTPServer(ServerWithCaseInsensitive())),
+			method:       http.MethodGet,
+			path:         "/USER/Tom/detail",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/user/Tom/Detail",
+		},
+		{
+			name:     "case insensitive exact",
+			server:   register(NewHTTPServer(ServerWithCaseInsensitive())),
+			method:   http.MethodGet,
+			path:     "/user/Tom/Detail",
+			wantCode: http.StatusOK,
+			wantResp: "/user/:id/Detail",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			recorder := httptest.NewRecorder()
+			tc.server.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantLocation, recorder.Header().Get("Location"))
+			assert.Equal(t, tc.wantResp, recorder.Body.String())
+		})
+	}
+}
+
+func TestHTTPServer_Shutdown(t *testing.T) {
+	s := NewHTTPServer()
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	s.Get("/slow", func(ctx *Context) {
+		close(started)
+		<-finish
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("done")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(l)
+	}()
+
+	respCh := make(chan *http.Response, 1)
+	go func() {
+		resp, er := http.Get("http://" + l.Addr().String() + "/slow")
+		if er != nil {
+			respCh <- nil
+			return
+		}
+		respCh <- resp
+	}()
+	<-started
+	assert.Equal(t, int64(1), s.InFlight())
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- s.Shutdown(context.Background())
+	}()
+
+	// 关闭中的新请求会被拒绝
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&s.rejecting) == 1
+	}, time.Second, time.Millisecond)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "close", recorder.Header().Get("Connection"))
+
+	// 正在处理的请求没有结束，Shutdown 不会返回
+	select {
+	case <-shutdownErr:
+		t.Fatal("Shutdown 没有等待正在处理的请求")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(finish)
+	resp := <-respCh
+	require.NotNil(t, resp)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "done", string(body))
+	assert.NoError(t, <-shutdownErr)
+	assert.Equal(t, http.ErrServerClosed, <-serveErr)
+	assert.Equal(t, int64(0), s.InFlight())
+
+	// 关闭之后不能再启动
+	assert.Equal(t, http.ErrServerClosed, s.Start("127.0.0.1:0"))
+}
+
+func TestHTTPServer_Handoff(t *testing.T) {
+	s := NewHTTPServer()
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	s.Get("/slow", func(ctx *Context) {
+		close(started)
+		<-finish
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("done")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(l)
+	}()
+
+	respCh := make(chan *http.Response, 1)
+	go func() {
+		resp, er := http.Get("http://" + addr + "/slow")
+		if er != nil {
+			respCh <- nil
+			return
+		}
+		respCh <- resp
+	}()
+	<-started
+
+	handoffErr := make(chan error, 1)
+	go func() {
+		handoffErr <- s.Handoff(context.Background())
+	}()
+	// 交接的时候关闭监听，新的连接交给别的进程
+	assert.Equal(t, http.ErrServerClosed, <-serveErr)
+	_, err = net.DialTimeout("tcp", addr, 100*time.Millisecond)
+	assert.Error(t, err)
+
+	// 已经接收的连接上的请求不会被拒绝
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// 正在处理的请求没有结束，Handoff 不会返回
+	select {
+	case <-handoffErr:
+		t.Fatal("Handoff 没有等待正在处理的请求")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(finish)
+	resp := <-respCh
+	require.NotNil(t, resp)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "done", string(body))
+	// 不再复用连接，客户端重新连接的时候由别的进程接收
+	assert.True(t, resp.Close)
+	assert.NoError(t, <-handoffErr)
+	assert.NoError(t, s.Shutdown(context.Background()))
+	assert.Equal(t, int64(0), s.Rejected())
+}
+
+func TestHTTPServer_Shutdown_Timeout(t *testing.T) {
+	s := NewHTTPServer()
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	defer close(finish)
+	s.Get("/slow", func(ctx *Context) {
+		close(started)
+		<-finish
+	})
+	// 没有通过 Start 启动，直接调用 ServeHTTP
+	go s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, s.Shutdown(ctx))
+	assert.Equal(t, int64(1), s.InFlight())
+}
@@ -0,0 +1,76 @@
+package cookie
+
+import (
+	"fmt"
+	"myhomework/homework2/session"
+	"net/http"
+)
+
+const defaultCookieName = "sessid"
+
+type PropagatorOption func(propagator *Propagator)
+
+// Propagator 通过 cookie 传递 session id
+type Propagator struct {
+	cookieName string
+	cookieOpt  func(c *http.Cookie)
+}
+
+func NewPropagator(opts ...PropagatorOption) *Propagator {
+	res := &Propagator{
+		cookieName: defaultCookieName,
+		cookieOpt: func(c *http.Cookie) {
+			c.Path = "/"
+			c.HttpOnly = true
+		},
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// WithCookieName 设置 cookie 的名字，默认是 sessid
+func WithCookieName(name string) PropagatorOption {
+	return func(propagator *Propagator) {
+		propagator.cookieName = name
+	}
+}
+
+// WithCookieOption 设置 cookie 的其它属性，例如 Domain、Secure、MaxAge
+// 会覆盖默认的 Path=/ 和 HttpOnly
+func WithCookieOption(opt func(c *http.Cookie)) PropagatorOption {
+	return func(propagator *Propagator) {
+		propagator.cookieOpt = opt
+	}
+}
+
+func (p *Propagator) Inject(id string, writer http.ResponseWriter) error {
+	c := &http.Cookie{
+		Name:  p.cookieName,
+		Value: id,
+	}
+	p.cookieOpt(c)
+	http.SetCookie(writer, c)
+	return nil
+}
+
+func (p *Propagator) Extract(req *http.Request) (string, error) {
+	c, err := req.Cookie(p.cookieName)
+	if err != nil || c.Value == "" {
+		return "", fmt.Errorf("%w, cookie: %s", session.ErrIDNotFound, p.cookieName)
+	}
+	return c.Value, nil
+}
+
+func (p *Propagator) Remove(writer http.ResponseWriter) error {
+	c := &http.Cookie{
+		Name:   p.cookieName,
+		MaxAge: -1,
+	}
+	p.cookieOpt(c)
+	// cookieOpt 可能设置了 MaxAge
+	c.MaxAge = -1
+	http.SetCookie(writer, c)
+	return nil
+}
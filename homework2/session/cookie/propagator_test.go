@@ -0,0 +1,31 @@
+package cookie
+
+import (
+	"myhomework/homework2/session"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagator(t *testing.T) {
+	p := NewPropagator(WithCookieName("my_sess"))
+
+	recorder := httptest.NewRecorder()
+	require.NoError(t, p.Inject("abc", recorder))
+	assert.Equal(t, "my_sess=abc; Path=/; HttpOnly", recorder.Header().Get("Set-Cookie"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := p.Extract(req)
+	assert.ErrorIs(t, err, session.ErrIDNotFound)
+	req.AddCookie(&http.Cookie{Name: "my_sess", Value: "abc"})
+	id, err := p.Extract(req)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", id)
+
+	recorder = httptest.NewRecorder()
+	require.NoError(t, p.Remove(recorder))
+	assert.Equal(t, "my_sess=; Path=/; Max-Age=0; HttpOnly", recorder.Header().Get("Set-Cookie"))
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	web "myhomework/homework2"
+)
+
+const defaultCtxSessKey = "_session"
+
+// Manager 组合 Store 和 Propagator，方便在 handler 里面使用
+// 同一个请求里面取出来的 session 会缓存在 ctx.UserValues 里面
+type Manager struct {
+	Store
+	Propagator
+	// CtxSessKey session 在 ctx.UserValues 里面的 key
+	CtxSessKey string
+	// IDGenerator 生成 session id，默认是 32 位的随机十六进制字符串
+	IDGenerator func() string
+}
+
+func NewManager(store Store, propagator Propagator) *Manager {
+	return &Manager{
+		Store:       store,
+		Propagator:  propagator,
+		CtxSessKey:  defaultCtxSessKey,
+		IDGenerator: generateID,
+	}
+}
+
+// GetSession 取出当前请求的 session
+func (m *Manager) GetSession(ctx *web.Context) (Session, error) {
+	if sess, ok := ctx.UserValues[m.CtxSessKey].(Session); ok {
+		return sess, nil
+	}
+	id, err := m.Extract(ctx.Req)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := m.Get(ctx.Req.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	m.cache(ctx, sess)
+	return sess, nil
+}
+
+// InitSession 创建一个新的 session，并且把 id 写到响应里面，一般在登录成功之后调用
+func (m *Manager) InitSession(ctx *web.Context) (Session, error) {
+	sess, err := m.Generate(ctx.Req.Context(), m.IDGenerator())
+	if err != nil {
+		return nil, err
+	}
+	if err = m.Inject(sess.ID(), ctx.Resp); err != nil {
+		return nil, err
+	}
+	m.cache(ctx, sess)
+	return sess, nil
+}
+
+// RefreshSession 刷新当前请求的 session 的过期时间
+func (m *Manager) RefreshSession(ctx *web.Context) error {
+	sess, err := m.GetSession(ctx)
+	if err != nil {
+		return err
+	}
+	if err = m.Refresh(ctx.Req.Context(), sess.ID()); err != nil {
+		return err
+	}
+	// 刷新 cookie 之类的过期时间
+	return m.Inject(sess.ID(), ctx.Resp)
+}
+
+// RemoveSession 删除当前请求的 session，一般在退出登录的时候调用
+func (m *Manager) RemoveSession(ctx *web.Context) error {
+	sess, err := m.GetSession(ctx)
+	if err != nil {
+		return err
+	}
+	if err = m.Store.Remove(ctx.Req.Context(), sess.ID()); err != nil {
+		return err
+	}
+	delete(ctx.UserValues, m.CtxSessKey)
+	return m.Propagator.Remove(ctx.Resp)
+}
+
+func (m *Manager) cache(ctx *web.Context, sess Session) {
+	if ctx.UserValues == nil {
+		ctx.UserValues = make(map[string]any, 1)
+	}
+	ctx.UserValues[m.CtxSessKey] = sess
+}
+
+func generateID() string {
+	var bs [16]byte
+	_, _ = rand.Read(bs[:])
+	return hex.EncodeToString(bs[:])
+}
@@ -0,0 +1,76 @@
+package session_test
+
+import (
+	web "myhomework/homework2"
+	"myhomework/homework2/session"
+	"myhomework/homework2/session/cookie"
+	"myhomework/homework2/session/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	store := memory.NewStore(time.Minute)
+	defer store.Close()
+	m := session.NewManager(store, cookie.NewPropagator())
+	m.IDGenerator = func() string {
+		return "abc"
+	}
+
+	s := web.NewHTTPServer()
+	s.Post("/login", func(ctx *web.Context) {
+		sess, err := m.InitSession(ctx)
+		require.NoError(t, err)
+		require.NoError(t, sess.Set(ctx.Req.Context(), "uid", 123))
+		// 同一个请求里面可以直接取出来
+		got, err := m.GetSession(ctx)
+		require.NoError(t, err)
+		assert.Same(t, sess, got)
+		ctx.RespStatusCode = http.StatusOK
+	})
+	s.Get("/profile", func(ctx *web.Context) {
+		sess, err := m.GetSession(ctx)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusUnauthorized
+			return
+		}
+		uid, err := sess.Get(ctx.Req.Context(), "uid")
+		require.NoError(t, err)
+		assert.Equal(t, 123, uid)
+		ctx.RespStatusCode = http.StatusOK
+	})
+	s.Post("/logout", func(ctx *web.Context) {
+		require.NoError(t, m.RemoveSession(ctx))
+		ctx.RespStatusCode = http.StatusOK
+	})
+
+	do := func(method, path string, c *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/profile", nil).Code)
+
+	recorder := do(http.MethodPost, "/login", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "abc", cookies[0].Value)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/profile", cookies[0]).Code)
+
+	recorder = do(http.MethodPost, "/logout", cookies[0])
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, -1, recorder.Result().Cookies()[0].MaxAge)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/profile", cookies[0]).Code)
+}
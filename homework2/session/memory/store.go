@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"myhomework/homework2/session"
+	cache "myhomework/homework_memory_limit"
+	"sync"
+	"time"
+)
+
+// Store 把 session 保存在内存里面
+// 过期时间交给 cache.MemoryMapCache 管理，缓存里面只保存 session id，
+// 缓存过期淘汰的时候通过回调把 session 一起删掉
+type Store struct {
+	mutex      sync.RWMutex
+	sessions   map[string]*Session
+	cache      *cache.MemoryMapCache
+	expiration time.Duration
+}
+
+// NewStore 创建内存 Store，session 在 expiration 内没有刷新就会过期
+func NewStore(expiration time.Duration) *Store {
+	res := &Store{
+		sessions:   make(map[string]*Session, 16),
+		expiration: expiration,
+	}
+	res.cache = cache.NewMemoryMapCache(expiration,
+		cache.MemoryMapCacheWithEvictedCallback(func(key string, val []byte) {
+			// 这里持有缓存的锁，所以 Store 不能在持有自己的锁的时候调用缓存
+			res.mutex.Lock()
+			delete(res.sessions, key)
+			res.mutex.Unlock()
+		}))
+	return res
+}
+
+func (s *Store) Generate(ctx context.Context, id string) (session.Session, error) {
+	sess := &Session{id: id, values: make(map[string]any, 4)}
+	s.mutex.Lock()
+	s.sessions[id] = sess
+	s.mutex.Unlock()
+	if err := s.cache.Set(ctx, id, nil, s.expiration); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+func (s *Store) Refresh(ctx context.Context, id string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.cache.Set(ctx, id, nil, s.expiration)
+}
+
+func (s *Store) Remove(ctx context.Context, id string) error {
+	// 回调会把 session 删掉
+	return s.cache.Delete(ctx, id)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (session.Session, error) {
+	// 缓存里面没有说明已经过期了
+	if _, err := s.cache.Get(ctx, id); err != nil {
+		return nil, fmt.Errorf("%w, id: %s", session.ErrSessionNotFound, id)
+	}
+	s.mutex.RLock()
+	sess, ok := s.sessions[id]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w, id: %s", session.ErrSessionNotFound, id)
+	}
+	return sess, nil
+}
+
+// Close 停止清理过期 session 的 goroutine
+func (s *Store) Close() error {
+	return s.cache.Close()
+}
+
+type Session struct {
+	id     string
+	mutex  sync.RWMutex
+	values map[string]any
+}
+
+func (s *Session) Get(ctx context.Context, key string) (any, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	val, ok := s.values[key]
+	if !ok {
+		return nil, fmt.Errorf("%w, key: %s", session.ErrKeyNotFound, key)
+	}
+	return val, nil
+}
+
+func (s *Session) Set(ctx context.Context, key string, val any) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[key] = val
+	return nil
+}
+
+func (s *Session) ID() string {
+	return s.id
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"myhomework/homework2/session"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(time.Second)
+	defer store.Close()
+	ctx := context.Background()
+
+	sess, err := store.Generate(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", sess.ID())
+	require.NoError(t, sess.Set(ctx, "uid", int64(123)))
+
+	got, err := store.Get(ctx, "abc")
+	require.NoError(t, err)
+	val, err := got.Get(ctx, "uid")
+	require.NoError(t, err)
+	assert.Equal(t, int64(123), val)
+	_, err = got.Get(ctx, "name")
+	assert.ErrorIs(t, err, session.ErrKeyNotFound)
+
+	require.NoError(t, store.Refresh(ctx, "abc"))
+	assert.ErrorIs(t, store.Refresh(ctx, "unknown"), session.ErrSessionNotFound)
+
+	require.NoError(t, store.Remove(ctx, "abc"))
+	_, err = store.Get(ctx, "abc")
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+	assert.Len(t, store.sessions, 0)
+}
+
+func TestStore_Expire(t *testing.T) {
+	store := NewStore(100 * time.Millisecond)
+	defer store.Close()
+	ctx := context.Background()
+
+	_, err := store.Generate(ctx, "abc")
+	require.NoError(t, err)
+	_, err = store.Generate(ctx, "def")
+	require.NoError(t, err)
+
+	// 刷新之后不会过期
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, store.Refresh(ctx, "abc"))
+	time.Sleep(60 * time.Millisecond)
+	_, err = store.Get(ctx, "abc")
+	assert.NoError(t, err)
+	_, err = store.Get(ctx, "def")
+	assert.ErrorIs(t, err, session.ErrSessionNotFound)
+
+	// 后台清理也会删掉 session
+	time.Sleep(250 * time.Millisecond)
+	store.mutex.RLock()
+	assert.Len(t, store.sessions, 0)
+	store.mutex.RUnlock()
+}
@@ -0,0 +1,42 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrKeyNotFound session 里面没有这个 key
+	ErrKeyNotFound = errors.New("session: key 不存在")
+	// ErrSessionNotFound session 不存在或者已经过期
+	ErrSessionNotFound = errors.New("session: session 不存在")
+	// ErrIDNotFound 请求里面没有 session id
+	ErrIDNotFound = errors.New("session: 请求里面没有 session id")
+)
+
+// Store 管理 session 本身，例如保存在内存或者 Redis 里面
+type Store interface {
+	// Generate 创建一个新的 session
+	Generate(ctx context.Context, id string) (Session, error)
+	// Refresh 刷新过期时间
+	Refresh(ctx context.Context, id string) error
+	Remove(ctx context.Context, id string) error
+	Get(ctx context.Context, id string) (Session, error)
+}
+
+type Session interface {
+	Get(ctx context.Context, key string) (any, error)
+	Set(ctx context.Context, key string, val any) error
+	ID() string
+}
+
+// Propagator 在请求和响应之间传递 session id，例如通过 cookie 或者 header
+type Propagator interface {
+	// Inject 把 session id 写到响应里面
+	Inject(id string, writer http.ResponseWriter) error
+	// Extract 从请求里面取出 session id
+	Extract(req *http.Request) (string, error)
+	// Remove 让客户端删除 session id
+	Remove(writer http.ResponseWriter) error
+}
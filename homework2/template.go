@@ -0,0 +1,155 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+)
+
+var errTemplateEngineNil = errors.New("web: 没有设置模板引擎")
+
+type TemplateEngine interface {
+	// Render 渲染页面
+	// tplName 模板的名字，按名索引
+	// data 渲染页面用的数据
+	Render(ctx context.Context, tplName string, data any) ([]byte, error)
+}
+
+type GoTemplateEngineOption func(engine *GoTemplateEngine)
+
+// GoTemplateEngine 基于 html/template 的实现
+// 模板分成两类：
+// - 页面：每一个页面文件单独解析，以文件名作为模板名字
+// - 共享模板：布局和片段，会被解析到每一个页面里面
+// 使用布局的时候，页面里面用 {{template "layout" .}} 引用布局，
+// 再用 {{define "content"}} 之类的语法填充布局里面的块。
+// 因为每个页面都是独立解析的，所以不同页面定义同名的块不会冲突
+type GoTemplateEngine struct {
+	fsys  fs.FS
+	pages []string
+	// layouts 包括布局和片段
+	layouts []string
+	funcs   template.FuncMap
+	// devMode 为 true 的时候，每次渲染都会重新加载模板，方便开发
+	devMode bool
+
+	mutex sync.RWMutex
+	tpls  map[string]*template.Template
+}
+
+// NewGoTemplateEngine 创建模板引擎
+// fsys 可以是 embed.FS，也可以用 os.DirFS，或者直接使用 NewGoTemplateEngineFromDir
+// pages 是页面的 glob 模式，例如 "pages/*.gohtml"
+func NewGoTemplateEngine(fsys fs.FS, pages []string, opts ...GoTemplateEngineOption) (*GoTemplateEngine, error) {
+	res := &GoTemplateEngine{
+		fsys:  fsys,
+		pages: pages,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	if err := res.Load(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// NewGoTemplateEngineFromDir 从目录中加载模板，patterns 是相对于 dir 的
+func NewGoTemplateEngineFromDir(dir string, pages []string, opts ...GoTemplateEngineOption) (*GoTemplateEngine, error) {
+	return NewGoTemplateEngine(os.DirFS(dir), pages, opts...)
+}
+
+// GoTemplateWithLayouts 设置布局和片段的 glob 模式，例如 "layouts/*.gohtml", "partials/*.gohtml"
+func GoTemplateWithLayouts(patterns ...string) GoTemplateEngineOption {
+	return func(engine *GoTemplateEngine) {
+		engine.layouts = append(engine.layouts, patterns...)
+	}
+}
+
+func GoTemplateWithFuncs(funcs template.FuncMap) GoTemplateEngineOption {
+	return func(engine *GoTemplateEngine) {
+		engine.funcs = funcs
+	}
+}
+
+// GoTemplateWithDevMode 开发模式下每次渲染都重新加载模板
+func GoTemplateWithDevMode() GoTemplateEngineOption {
+	return func(engine *GoTemplateEngine) {
+		engine.devMode = true
+	}
+}
+
+// Load 重新加载所有的模板
+func (g *GoTemplateEngine) Load() error {
+	shared := template.New("").Funcs(g.funcs)
+	for _, pattern := range g.layouts {
+		matches, err := fs.Glob(g.fsys, pattern)
+		if err != nil {
+			return err
+		}
+		// 没有匹配的时候 ParseFS 会报错，但是没有布局是合法的
+		if len(matches) == 0 {
+			continue
+		}
+		if shared, err = shared.ParseFS(g.fsys, pattern); err != nil {
+			return err
+		}
+	}
+
+	tpls := make(map[string]*template.Template, 16)
+	for _, pattern := range g.pages {
+		matches, err := fs.Glob(g.fsys, pattern)
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			name := path.Base(m)
+			if _, ok := tpls[name]; ok {
+				return fmt.Errorf("web: 模板名字冲突 %s", name)
+			}
+			tpl, err := shared.Clone()
+			if err != nil {
+				return err
+			}
+			if tpl, err = tpl.New(name).ParseFS(g.fsys, m); err != nil {
+				return err
+			}
+			tpls[name] = tpl
+		}
+	}
+
+	g.mutex.Lock()
+	g.tpls = tpls
+	g.mutex.Unlock()
+	return nil
+}
+
+func (g *GoTemplateEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	if g.devMode {
+		if err := g.Load(); err != nil {
+			return nil, err
+		}
+	}
+	g.mutex.RLock()
+	tpl, ok := g.tpls[tplName]
+	g.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("web: 模板 %s 不存在", tplName)
+	}
+	bs := &bytes.Buffer{}
+	err := tpl.ExecuteTemplate(bs, tplName, data)
+	return bs.Bytes(), err
+}
+
+// ServerWithTemplateEngine 设置模板引擎
+func ServerWithTemplateEngine(engine TemplateEngine) HTTPServerOption {
+	return func(server *HTTPServer) {
+		server.tplEngine = engine
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoTemplateEngine_Render(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/layout.gohtml":  {Data: []byte(`{{define "layout"}}<html>{{template "header" .}}{{template "content" .}}</html>{{end}}`)},
+		"partials/header.gohtml": {Data: []byte(`{{define "header"}}<h1>{{.Title}}</h1>{{end}}`)},
+		"pages/user.gohtml":      {Data: []byte(`{{template "layout" .}}{{define "content"}}<p>{{.Name}}</p>{{end}}`)},
+		"pages/order.gohtml":     {Data: []byte(`{{template "layout" .}}{{define "content"}}<span>{{.Name}}</span>{{end}}`)},
+		"pages/plain.gohtml":     {Data: []byte(`hello, {{.Name}}`)},
+	}
+	engine, err := NewGoTemplateEngine(fsys, []string{"pages/*.gohtml"},
+		GoTemplateWithLayouts("layouts/*.gohtml", "partials/*.gohtml"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		tplName string
+		data    any
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "layout",
+			tplName: "user.gohtml",
+			data:    map[string]string{"Title": "User", "Name": "<Tom>"},
+			want:    "<html><h1>User</h1><p>&lt;Tom&gt;</p></html>",
+		},
+		{
+			// 不同页面的同名块不冲突
+			name:    "another page",
+			tplName: "order.gohtml",
+			data:    map[string]string{"Title": "Order", "Name": "123"},
+			want:    "<html><h1>Order</h1><span>123</span></html>",
+		},
+		{
+			name:    "no layout",
+			tplName: "plain.gohtml",
+			data:    map[string]string{"Name": "Tom"},
+			want:    "hello, Tom",
+		},
+		{
+			name:    "not found",
+			tplName: "unknown.gohtml",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bs, err := engine.Render(context.Background(), tc.tplName, tc.data)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(bs))
+		})
+	}
+}
+
+func TestGoTemplateEngine_DevMode(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hello.gohtml")
+	require.NoError(t, os.WriteFile(file, []byte(`hello, {{.}}`), 0644))
+	engine, err := NewGoTemplateEngineFromDir(dir, []string{"*.gohtml"}, GoTemplateWithDevMode())
+	require.NoError(t, err)
+
+	bs, err := engine.Render(context.Background(), "hello.gohtml", "Tom")
+	require.NoError(t, err)
+	assert.Equal(t, "hello, Tom", string(bs))
+
+	// 开发模式下修改模板立刻生效
+	require.NoError(t, os.WriteFile(file, []byte(`hi, {{.}}`), 0644))
+	bs, err = engine.Render(context.Background(), "hello.gohtml", "Tom")
+	require.NoError(t, err)
+	assert.Equal(t, "hi, Tom", string(bs))
+}
+
+func TestContext_Render(t *testing.T) {
+	fsys := fstest.MapFS{
+		"hello.gohtml": {Data: []byte(`hello, {{.}}`)},
+	}
+	engine, err := NewGoTemplateEngine(fsys, []string{"*.gohtml"})
+	require.NoError(t, err)
+	s := NewHTTPServer(ServerWithTemplateEngine(engine))
+	s.Get("/hello", func(ctx *Context) {
+		_ = ctx.Render("hello.gohtml", "Tom")
+	})
+	s.Get("/unknown", func(ctx *Context) {
+		_ = ctx.Render("unknown.gohtml", "Tom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "hello, Tom", recorder.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/unknown", nil)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
@@ -0,0 +1,221 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldError 描述一个字段的校验失败
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError 包含所有校验失败的字段
+// 一般来说，用户应该直接把它作为 400 的响应返回，例如
+// ctx.RespJSON(http.StatusBadRequest, err)
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return "web: 参数校验失败 " + strings.Join(msgs, "; ")
+}
+
+// validate 按照 validate 标签校验结构体，多条规则用逗号分隔，目前支持
+// - required：不能是零值
+// - min=n, max=n：对于数字是取值范围，对于字符串、切片和 map 是长度范围
+// - oneof=a b c：只能是其中一个值
+// 嵌套的结构体也会被校验，字段名字用 . 连起来
+// tagName 决定了错误里面的字段名字，例如 json 就用 json 标签里面的名字
+// 每个类型的标签只解析一次，标签写错了的话返回普通的 error，而不是 *ValidationError
+func validate(val any, tagName string) error {
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var fes []FieldError
+	if err := validateStruct(rv, "", tagName, &fes); err != nil {
+		return err
+	}
+	if len(fes) > 0 {
+		return &ValidationError{Fields: fes}
+	}
+	return nil
+}
+
+type structRulesKey struct {
+	typ     reflect.Type
+	tagName string
+}
+
+// structRules 解析好的一个结构体类型的校验规则
+type structRules struct {
+	fields []fieldRules
+	err    error
+}
+
+type fieldRules struct {
+	index int
+	name  string
+	rules []rule
+}
+
+type rule struct {
+	name  string
+	param string
+	limit float64
+}
+
+// rulesCache 缓存 structRulesKey 到 *structRules
+var rulesCache sync.Map
+
+func getStructRules(typ reflect.Type, tagName string) *structRules {
+	key := structRulesKey{typ: typ, tagName: tagName}
+	if res, ok := rulesCache.Load(key); ok {
+		return res.(*structRules)
+	}
+	res, _ := rulesCache.LoadOrStore(key, parseStructRules(typ, tagName))
+	return res.(*structRules)
+}
+
+// parseStructRules 解析结构体的 validate 标签，嵌套的结构体在校验的时候再解析，
+// 这样自引用的类型也不会无限递归
+func parseStructRules(typ reflect.Type, tagName string) *structRules {
+	res := &structRules{}
+	for i := 0; i < typ.NumField(); i++ {
+		fd := typ.Field(i)
+		if !fd.IsExported() {
+			continue
+		}
+		fr := fieldRules{index: i, name: fieldName(fd, tagName)}
+		if tag, ok := fd.Tag.Lookup("validate"); ok && tag != "" {
+			for _, r := range strings.Split(tag, ",") {
+				parsed, err := parseRule(r)
+				if err != nil {
+					res.err = fmt.Errorf("%w, 字段 %s.%s", err, typ.Name(), fd.Name)
+					return res
+				}
+				fr.rules = append(fr.rules, parsed)
+			}
+		}
+		res.fields = append(res.fields, fr)
+	}
+	return res
+}
+
+func parseRule(r string) (rule, error) {
+	name, param, _ := strings.Cut(r, "=")
+	res := rule{name: name, param: param}
+	switch name {
+	case "required", "oneof":
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return res, fmt.Errorf("web: 非法的校验规则 %s", r)
+		}
+		res.limit = limit
+	default:
+		return res, fmt.Errorf("web: 不支持的校验规则 %s", r)
+	}
+	return res, nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, tagName string, fes *[]FieldError) error {
+	sr := getStructRules(rv.Type(), tagName)
+	if sr.err != nil {
+		return sr.err
+	}
+	for _, fr := range sr.fields {
+		name := prefix + fr.name
+		fv := rv.Field(fr.index)
+		for _, r := range fr.rules {
+			if msg, ok := checkRule(fv, r); !ok {
+				*fes = append(*fes, FieldError{Field: name, Rule: r.name, Message: msg})
+			}
+		}
+		// 继续校验嵌套结构体
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			if err := validateStruct(fv, name+".", tagName, fes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRule 校验单条规则，不通过的时候返回错误信息和 false
+func checkRule(fv reflect.Value, r rule) (string, bool) {
+	switch r.name {
+	case "required":
+		if fv.IsZero() {
+			return "不能为空", false
+		}
+	case "min", "max":
+		v, ok := measure(fv)
+		if !ok {
+			return "", true
+		}
+		if r.name == "min" && v < r.limit {
+			return fmt.Sprintf("不能小于 %s", r.param), false
+		}
+		if r.name == "max" && v > r.limit {
+			return fmt.Sprintf("不能大于 %s", r.param), false
+		}
+	case "oneof":
+		for fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				return "", true
+			}
+			fv = fv.Elem()
+		}
+		s := fmt.Sprint(fv.Interface())
+		for _, opt := range strings.Fields(r.param) {
+			if s == opt {
+				return "", true
+			}
+		}
+		return fmt.Sprintf("必须是 [%s] 其中之一", r.param), false
+	}
+	return "", true
+}
+
+// measure 返回用于比较的大小，数字就是它本身，字符串、切片和 map 是长度
+func measure(fv reflect.Value) (float64, bool) {
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return 0, false
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), true
+	case reflect.String:
+		return float64(len([]rune(fv.String()))), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true
+	}
+	return 0, false
+}
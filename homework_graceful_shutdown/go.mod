@@ -1,3 +1,16 @@
 module homework_graceful_shutdown
 
 go 1.18
+
+require (
+	github.com/stretchr/testify v1.8.1
+	myhomework v0.0.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace myhomework => ../
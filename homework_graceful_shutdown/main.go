@@ -15,8 +15,24 @@ func main() {
 		_, _ = writer.Write([]byte("hello\n"))
 	}))
 	s2 := service.NewServer("admin", "localhost:8081")
-	app := service.NewApp([]*service.Server{s1, s2}, service.WithShutdownCallbacks(StoreCacheToDBCallback))
+	app := service.NewApp([]service.Server{s1, s2},
+		service.WithHealthServer(s2),
+		service.WithReadinessDelay(time.Second),
+		service.WithShutdownCallbacks(StoreCacheToDBCallback),
+		// 缓存刷新到数据库之后才能关闭连接池
+		service.WithShutdownCallbackE("close-db", CloseDBCallback,
+			service.CallbackWithPhase(service.PhaseClose),
+			service.CallbackWithTimeout(time.Second)))
 	app.StartAndServe()
+	if err := app.Err(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func CloseDBCallback(ctx context.Context) error {
+	// 这里模拟关闭数据库连接池
+	log.Printf("关闭数据库连接池")
+	return nil
 }
 
 func StoreCacheToDBCallback(ctx context.Context) {
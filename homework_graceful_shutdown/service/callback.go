@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 预定义的回调阶段，阶段小的先执行，同一个阶段的回调并发执行
+// 例如先把本地缓存刷新到数据库，再关闭数据库连接池
+const (
+	PhaseFlush   = 100
+	PhaseDefault = 200
+	PhaseClose   = 300
+)
+
+// ShutdownCallbackE 返回 error 的回调，错误会被收集到 ShutdownSummary 里面
+type ShutdownCallbackE func(ctx context.Context) error
+
+type CallbackOption func(cb *callback)
+
+// CallbackWithPhase 设置回调所在的阶段，默认是 PhaseDefault
+func CallbackWithPhase(phase int) CallbackOption {
+	return func(cb *callback) {
+		cb.phase = phase
+	}
+}
+
+// CallbackWithTimeout 设置回调自己的超时时间，默认使用 App 的 cbTimeout
+func CallbackWithTimeout(timeout time.Duration) CallbackOption {
+	return func(cb *callback) {
+		cb.timeout = timeout
+	}
+}
+
+// WithShutdownCallbacks 注册不返回 error 的回调，它们都在 PhaseDefault 阶段执行
+// 多次调用会追加，而不是覆盖
+func WithShutdownCallbacks(cbs ...ShutdownCallback) Option {
+	return func(a *App) {
+		for _, cb := range cbs {
+			fn := cb
+			a.cbs = append(a.cbs, &callback{
+				name:  fmt.Sprintf("callback-%d", len(a.cbs)),
+				phase: PhaseDefault,
+				fn: func(ctx context.Context) error {
+					fn(ctx)
+					return nil
+				},
+			})
+		}
+	}
+}
+
+// WithShutdownCallbackE 注册一个带名字的回调，名字用于日志和 ShutdownSummary
+func WithShutdownCallbackE(name string, fn ShutdownCallbackE, opts ...CallbackOption) Option {
+	return func(a *App) {
+		cb := &callback{name: name, phase: PhaseDefault, fn: fn}
+		for _, opt := range opts {
+			opt(cb)
+		}
+		a.cbs = append(a.cbs, cb)
+	}
+}
+
+type callback struct {
+	name  string
+	phase int
+	// timeout 为 0 的时候使用 App 的 cbTimeout
+	timeout time.Duration
+	fn      ShutdownCallbackE
+}
+
+// CallbackResult 一个回调的执行结果
+type CallbackResult struct {
+	Name     string
+	Phase    int
+	Duration time.Duration
+	// Err 回调返回的错误，超时的时候是 context.DeadlineExceeded，panic 的时候也会转成错误
+	Err error
+}
+
+// ShutdownSummary 关闭过程的汇总
+type ShutdownSummary struct {
+	Callbacks []CallbackResult
+}
+
+// Err 把所有回调的错误合并成一个，没有错误的时候返回 nil
+func (s ShutdownSummary) Err() error {
+	var msgs []string
+	for _, r := range s.Callbacks {
+		if r.Err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", r.Name, r.Err))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("service: %d 个回调执行失败 [%s]", len(msgs), strings.Join(msgs, "; "))
+}
+
+func (s ShutdownSummary) String() string {
+	var sb strings.Builder
+	sb.WriteString("关闭汇总：")
+	for _, r := range s.Callbacks {
+		status := "成功"
+		if r.Err != nil {
+			status = "失败 " + r.Err.Error()
+		}
+		sb.WriteString(fmt.Sprintf("\n  [阶段 %d] %s 耗时 %v %s", r.Phase, r.Name, r.Duration, status))
+	}
+	return sb.String()
+}
+
+// runCallbacks 按照阶段执行回调，一个阶段的回调都结束之后才会进入下一个阶段
+func (app *App) runCallbacks() ShutdownSummary {
+	cbs := make([]*callback, len(app.cbs))
+	copy(cbs, app.cbs)
+	// 稳定排序，同一个阶段保持注册顺序，方便看日志
+	sort.SliceStable(cbs, func(i, j int) bool {
+		return cbs[i].phase < cbs[j].phase
+	})
+
+	results := make([]CallbackResult, len(cbs))
+	for start := 0; start < len(cbs); {
+		end := start
+		for end < len(cbs) && cbs[end].phase == cbs[start].phase {
+			end++
+		}
+		log.Printf("开始执行阶段 %d 的回调", cbs[start].phase)
+		var wg sync.WaitGroup
+		wg.Add(end - start)
+		for i := start; i < end; i++ {
+			go func(i int) {
+				defer wg.Done()
+				results[i] = app.runCallback(cbs[i])
+			}(i)
+		}
+		wg.Wait()
+		start = end
+	}
+	return ShutdownSummary{Callbacks: results}
+}
+
+// runCallback 执行一个回调，超时之后不再等待它返回
+func (app *App) runCallback(cb *callback) CallbackResult {
+	timeout := cb.timeout
+	if timeout <= 0 {
+		timeout = app.cbTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("service: 回调 panic %v", r)
+			}
+		}()
+		done <- cb.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		log.Printf("回调%s执行失败 %v", cb.name, err)
+	}
+	return CallbackResult{
+		Name:     cb.name,
+		Phase:    cb.phase,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// HealthChecker 检查一个依赖是不是可用，例如数据库、缓存、下游的 rpc 服务
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// HealthCheck 用函数创建 HealthChecker
+func HealthCheck(name string, fn func(ctx context.Context) error) HealthChecker {
+	return &funcChecker{name: name, fn: fn}
+}
+
+// Pinger 能够 ping 的依赖，例如 *sql.DB 和 ORM 的 DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingChecker 通过 PingContext 检查依赖
+func PingChecker(name string, p Pinger) HealthChecker {
+	return HealthCheck(name, p.PingContext)
+}
+
+type funcChecker struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (f *funcChecker) Name() string {
+	return f.name
+}
+
+func (f *funcChecker) Check(ctx context.Context) error {
+	return f.fn(ctx)
+}
+
+// HealthReport /healthz 和 /readyz 返回的 JSON
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// WithHealthServer 在 srv 上注册 /healthz 和 /readyz，一般是 admin 服务器
+// srv 也需要传给 NewApp。这两个路径在关闭过程中不会被拒绝
+func WithHealthServer(srv *HTTPServer) Option {
+	return func(a *App) {
+		srv.handleProbe("/healthz", http.HandlerFunc(a.serveHealthz))
+		srv.handleProbe("/readyz", http.HandlerFunc(a.serveReadyz))
+	}
+}
+
+// WithHealthCheckers 注册依赖检查，/readyz 会执行这些检查
+func WithHealthCheckers(checkers ...HealthChecker) Option {
+	return func(a *App) {
+		a.checkers = append(a.checkers, checkers...)
+	}
+}
+
+// WithHealthCheckTimeout 设置每一个依赖检查的超时时间，默认三秒钟
+func WithHealthCheckTimeout(timeout time.Duration) Option {
+	return func(a *App) {
+		a.checkTimeout = timeout
+	}
+}
+
+// WithReadinessDelay 开始关闭之后，/readyz 立刻返回 503，
+// 但是要等 delay 之后才拒绝新请求，给负载均衡摘除实例的时间
+func WithReadinessDelay(delay time.Duration) Option {
+	return func(a *App) {
+		a.readinessDelay = delay
+	}
+}
+
+// Ready 所有的服务器都在监听，并且还没有开始关闭
+// 按照 PolicyIgnore 退出的服务器，或者等待重启的服务器，都会让就绪检查失败
+func (app *App) Ready() bool {
+	return atomic.LoadInt32(&app.closing) == 0 &&
+		atomic.LoadInt32(&app.listening) == int32(len(app.servers))
+}
+
+// CheckHealth 并发执行所有的依赖检查
+func (app *App) CheckHealth(ctx context.Context) HealthReport {
+	report := HealthReport{
+		Status: HealthStatusUp,
+		Checks: make(map[string]CheckResult, len(app.checkers)),
+	}
+	results := make([]CheckResult, len(app.checkers))
+	var wg sync.WaitGroup
+	wg.Add(len(app.checkers))
+	for i, c := range app.checkers {
+		go func(i int, c HealthChecker) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, app.checkTimeout)
+			defer cancel()
+			start := time.Now()
+			err := c.Check(cctx)
+			res := CheckResult{Status: HealthStatusUp, Duration: time.Since(start).String()}
+			if err != nil {
+				res.Status = HealthStatusDown
+				res.Error = err.Error()
+			}
+			results[i] = res
+		}(i, c)
+	}
+	wg.Wait()
+	for i, c := range app.checkers {
+		report.Checks[c.Name()] = results[i]
+		if results[i].Status != HealthStatusUp {
+			report.Status = HealthStatusDown
+		}
+	}
+	return report
+}
+
+// serveHealthz 存活检查，只要进程还能处理请求就是健康的，不检查依赖
+// 依赖不可用的时候重启进程也没有用
+func (app *App) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, HealthReport{Status: HealthStatusUp})
+}
+
+// serveReadyz 就绪检查，没有启动完成、开始关闭或者依赖不可用的时候返回 503
+func (app *App) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	if !app.Ready() {
+		writeReport(w, HealthReport{Status: HealthStatusDown})
+		return
+	}
+	writeReport(w, app.CheckHealth(r.Context()))
+}
+
+func writeReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if report.Status != HealthStatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
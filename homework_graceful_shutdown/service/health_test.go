@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_Health(t *testing.T) {
+	admin := NewServer("admin", "127.0.0.1:0")
+	var dbErr error
+	app := NewApp([]Server{admin},
+		WithSignalChannel(make(chan os.Signal)),
+		WithHealthServer(admin),
+		WithHealthCheckers(
+			HealthCheck("db", func(ctx context.Context) error {
+				return dbErr
+			}),
+			HealthCheck("cache", func(ctx context.Context) error {
+				return nil
+			}),
+		),
+		WithReadinessDelay(200*time.Millisecond),
+	)
+
+	probe := func(path string) (int, HealthReport) {
+		recorder := httptest.NewRecorder()
+		admin.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		var report HealthReport
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+		return recorder.Code, report
+	}
+
+	// 还没有启动
+	code, report := probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthStatusDown, report.Status)
+	code, _ = probe("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	go app.StartAndServe()
+	require.Eventually(t, app.Ready, time.Second, time.Millisecond)
+	code, report = probe("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthStatusUp, report.Status)
+	assert.Equal(t, HealthStatusUp, report.Checks["db"].Status)
+
+	// 依赖不可用
+	dbErr = errors.New("mock error")
+	code, report = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthStatusDown, report.Status)
+	assert.Equal(t, CheckResult{Status: HealthStatusDown, Error: "mock error", Duration: report.Checks["db"].Duration}, report.Checks["db"])
+	assert.Equal(t, HealthStatusUp, report.Checks["cache"].Status)
+	dbErr = nil
+
+	go func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	// 开始关闭之后就绪检查立刻失败，但是业务请求在 delay 之后才会被拒绝
+	require.Eventually(t, func() bool {
+		return !app.Ready()
+	}, time.Second, time.Millisecond)
+	code, _ = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	recorder := httptest.NewRecorder()
+	admin.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/other", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// 拒绝请求之后，健康检查仍然可以访问
+	require.Eventually(t, func() bool {
+		recorder := httptest.NewRecorder()
+		admin.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/other", nil))
+		return recorder.Code == http.StatusServiceUnavailable
+	}, time.Second, time.Millisecond)
+	code, _ = probe("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	<-app.Done()
+}
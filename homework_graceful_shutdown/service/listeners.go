@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// envListeners 父进程交给子进程的 listener，用逗号分隔，顺序和文件描述符一致
+	envListeners = "GRACEFUL_LISTENERS"
+	// envReadyFD 子进程启动完毕之后，往这个文件描述符里面写数据通知父进程
+	envReadyFD = "GRACEFUL_READY_FD"
+	// 0、1、2 是标准输入输出，ExtraFiles 从 3 开始
+	firstExtraFD = 3
+)
+
+var errListenerNoFile = errors.New("service: listener 不支持导出文件描述符")
+
+// Listeners 管理监听的 socket，重启的时候把它们交给子进程
+// 同一个进程里面要用同一个 network 和 addr 调用 Listen，子进程才能找到对应的 socket
+type Listeners struct {
+	mutex sync.Mutex
+	// inherited 从父进程继承的，还没有被使用的 listener
+	inherited map[string]net.Listener
+	keys      []string
+	active    map[string]net.Listener
+}
+
+// NewListeners 创建 Listeners，如果是由父进程启动的，会接管父进程交过来的 listener
+// 环境变量只会被接管一次
+func NewListeners() *Listeners {
+	res := &Listeners{
+		inherited: make(map[string]net.Listener, 4),
+		active:    make(map[string]net.Listener, 4),
+	}
+	val := os.Getenv(envListeners)
+	if val == "" {
+		return res
+	}
+	_ = os.Unsetenv(envListeners)
+	for i, key := range strings.Split(val, ",") {
+		f := os.NewFile(uintptr(firstExtraFD+i), key)
+		l, err := net.FileListener(f)
+		// FileListener 会复制文件描述符，所以原来的要关掉
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+		res.inherited[key] = l
+	}
+	return res
+}
+
+var (
+	defaultListeners     *Listeners
+	defaultListenersOnce sync.Once
+)
+
+// DefaultListeners 默认的 Listeners，HTTPServer 和 App 默认都使用它
+func DefaultListeners() *Listeners {
+	defaultListenersOnce.Do(func() {
+		defaultListeners = NewListeners()
+	})
+	return defaultListeners
+}
+
+// Listen 使用 DefaultListeners 监听，web 的 HTTPServer 和 rpc 的 Server 可以这样使用：
+//
+//	l, err := service.Listen("tcp", ":8080")
+//	webSrv.Serve(l)
+func Listen(network, addr string) (net.Listener, error) {
+	return DefaultListeners().Listen(network, addr)
+}
+
+// Listen 优先使用从父进程继承的 listener，没有的话重新监听
+func (ls *Listeners) Listen(network, addr string) (net.Listener, error) {
+	key := network + "://" + addr
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	l, ok := ls.inherited[key]
+	if ok {
+		delete(ls.inherited, key)
+	} else {
+		var err error
+		if l, err = net.Listen(network, addr); err != nil {
+			return nil, err
+		}
+	}
+	// 服务器重启的时候会再次监听，替换掉原来已经关闭的 listener
+	if _, ok = ls.active[key]; !ok {
+		ls.keys = append(ls.keys, key)
+	}
+	ls.active[key] = l
+	return l, nil
+}
+
+// files 复制所有的 listener 的文件描述符，调用者负责关闭
+func (ls *Listeners) files() ([]string, []*os.File, error) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	files := make([]*os.File, 0, len(ls.keys))
+	for _, key := range ls.keys {
+		filer, ok := ls.active[key].(interface{ File() (*os.File, error) })
+		if !ok {
+			closeFiles(files)
+			return nil, nil, fmt.Errorf("%w, %s", errListenerNoFile, key)
+		}
+		f, err := filer.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	keys := make([]string, len(ls.keys))
+	copy(keys, ls.keys)
+	return keys, files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
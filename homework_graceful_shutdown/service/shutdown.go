@@ -3,10 +3,14 @@ package service
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -19,16 +23,9 @@ type Option func(*App)
 // - 我们还希望用户知道，他的回调必须要在一定时间内处理完毕，而且他必须显式处理超时错误
 type ShutdownCallback func(ctx context.Context)
 
-// 你需要实现这个方法
-func WithShutdownCallbacks(cbs ...ShutdownCallback) Option {
-	return func(a *App) {
-		a.cbs = cbs
-	}
-}
-
 // 这里我已经预先定义好了各种可配置字段
 type App struct {
-	servers []*Server
+	servers []Server
 
 	// 优雅退出整个超时时间，默认30秒
 	shutdownTimeout time.Duration
@@ -38,121 +35,354 @@ type App struct {
 	// 自定义回调超时时间，默认三秒钟
 	cbTimeout time.Duration
 
-	cbs []ShutdownCallback
+	cbs []*callback
+	// summary 最近一次关闭的汇总
+	summary ShutdownSummary
+
+	// closing 不为 0 说明已经开始关闭
+	closing int32
+	// listening 正在监听的服务器数量，全部都在监听并且没有开始关闭的时候才算就绪
+	listening int32
+	// serving 所有的服务器第一次都在监听的时候关闭
+	serving     chan struct{}
+	servingOnce sync.Once
+	// readinessDelay 开始关闭之后，等多久才拒绝新请求
+	readinessDelay time.Duration
+
+	checkers     []HealthChecker
+	checkTimeout time.Duration
+
+	// policies 按照服务器名字设置的失败策略，默认是 PolicyFail
+	policies       map[string]FailurePolicy
+	backoffInitial time.Duration
+	backoffMax     time.Duration
+	maxRestarts    int
+	// restartResetAfter 服务器运行超过这个时间之后退出，重启次数和退避时间重新计算，为 0 的时候使用 backoffMax
+	restartResetAfter time.Duration
+
+	startupHooks   []startupHook
+	startupTimeout time.Duration
+
+	listeners      *Listeners
+	upgradeCmd     func() *exec.Cmd
+	upgradeTimeout time.Duration
+	// upgrading 不为 0 说明正在零停机重启
+	upgrading int32
+	// upgraded 不为 0 说明子进程已经就绪，接管了监听的 socket
+	upgraded int32
+
+	errs     chan error
+	errMutex sync.Mutex
+	// err 导致应用关闭的错误
+	err error
+
+	// signals 为 nil 的时候监听进程的 SIGINT 和 SIGTERM
+	signals <-chan os.Signal
+	// exit 强制退出，默认是 os.Exit
+	exit func(code int)
+
+	shutdownOnce sync.Once
+	// stopping 开始关闭的时候关闭这个 channel
+	stopping chan struct{}
+	// done 关闭完成的时候关闭这个 channel
+	done chan struct{}
+}
+
+// WithShutdownTimeout 设置优雅退出整个超时时间，超时之后调用 exit(2)
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(a *App) {
+		a.shutdownTimeout = timeout
+	}
+}
+
+// WithWaitTime 设置等待正在执行的请求的时间
+func WithWaitTime(waitTime time.Duration) Option {
+	return func(a *App) {
+		a.waitTime = waitTime
+	}
+}
+
+// WithCallbackTimeout 设置回调默认的超时时间
+func WithCallbackTimeout(timeout time.Duration) Option {
+	return func(a *App) {
+		a.cbTimeout = timeout
+	}
+}
+
+// WithSignalChannel 使用 ch 代替进程信号，一般用于测试
+func WithSignalChannel(ch <-chan os.Signal) Option {
+	return func(a *App) {
+		a.signals = ch
+	}
+}
+
+// WithExitFunc 替换强制退出的 os.Exit，一般用于测试
+// 第二次收到信号的时候 code 是 1，关闭超时的时候 code 是 2
+func WithExitFunc(fn func(code int)) Option {
+	return func(a *App) {
+		a.exit = fn
+	}
 }
 
 // NewApp 创建 App 实例，注意设置默认值，同时使用这些选项
-func NewApp(servers []*Server, opts ...Option) *App {
+func NewApp(servers []Server, opts ...Option) *App {
 
 	app := &App{
 		servers:         servers,
 		shutdownTimeout: 30 * time.Second,
 		waitTime:        10 * time.Second,
 		cbTimeout:       13 * time.Second,
+		checkTimeout:    3 * time.Second,
+		policies:        make(map[string]FailurePolicy, len(servers)),
+		backoffInitial:  time.Second,
+		backoffMax:      30 * time.Second,
+		startupTimeout:  30 * time.Second,
+		errs:            make(chan error, 16),
+		upgradeCmd:      defaultUpgradeCommand,
+		upgradeTimeout:  30 * time.Second,
+		exit:            os.Exit,
+		serving:         make(chan struct{}),
+		stopping:        make(chan struct{}),
+		done:            make(chan struct{}),
 	}
 
 	for _, opt := range opts {
 		opt(app)
 	}
+	if app.listeners == nil {
+		app.listeners = DefaultListeners()
+	}
 
 	return app
 }
 
-// StartAndServe 你主要要实现这个方法
+// StartAndServe 执行启动钩子，启动所有的服务器，然后一直阻塞到应用关闭
+// 启动失败或者服务器按照 PolicyFail 退出的时候，可以通过 Err 拿到原因
 func (app *App) StartAndServe() {
+	if err := app.startup(); err != nil {
+		app.fail(err)
+		<-app.done
+		return
+	}
+	if len(app.servers) == 0 {
+		app.markServing()
+	}
 	for _, s := range app.servers {
-		srv := s
-		go func() {
-			if err := srv.Start(); err != nil {
-				if err == http.ErrServerClosed {
-					log.Printf("服务器%s已关闭", srv.name)
-				} else {
-					log.Printf("服务器%s异常退出", srv.name)
-				}
-			}
-		}()
+		go app.supervise(s)
 	}
+	// 如果是零停机重启启动的子进程，所有的服务器都在监听之后，通知父进程可以退出了
+	go app.notifyParentWhenServing()
 	// 从这里开始优雅退出监听系统信号，强制退出以及超时强制退出。
 	// 优雅退出的具体步骤在 shutdown 里面实现
 	// 所以你需要在这里恰当的位置，调用 shutdown
 
 	// 注册信号
-	exit := make(chan os.Signal, 1)
-	signal.Notify(exit, syscall.SIGINT, syscall.SIGTERM)
+	exit := app.signals
+	if exit == nil {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM}, upgradeSignals...)...)
+		defer signal.Stop(ch)
+		exit = ch
+	}
 
-	// 监听信号
-	<-exit // 第一次收到信号
+	// 监听信号，或者通过 Shutdown 关闭
+	for waiting := true; waiting; {
+		select {
+		case sig := <-exit: // 第一次收到信号
+			if !isUpgradeSignal(sig) {
+				waiting = false
+				break
+			}
+			// 重启成功之后会走 stopping 分支
+			go func() {
+				if err := app.Upgrade(); err != nil {
+					log.Printf("零停机重启失败 %v", err)
+				}
+			}()
+		case <-app.stopping:
+			<-app.done
+			return
+		}
+	}
 
 	// 开一个gorouting监听
 	//   1. 是否有连续的信号
 	//   2. 是否关闭超时
 	go func() {
+		timer := time.NewTimer(app.shutdownTimeout)
+		defer timer.Stop()
 		select {
 		case <-exit: // 第二次收到信号，立刻退出
-			os.Exit(1)
-		case <-time.After(app.shutdownTimeout):
-			os.Exit(2)
+			app.exit(1)
+		case <-timer.C:
+			app.exit(2)
+		case <-app.done:
 		}
 	}()
 
-	app.shutdown()
+	app.startShutdown()
+	<-app.done
+}
 
+func isUpgradeSignal(sig os.Signal) bool {
+	for _, s := range upgradeSignals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// Shutdown 主动关闭应用，和收到信号的效果一样
+// ctx 只控制等待的时间，ctx 超时之后关闭过程还会在后台继续
+// 返回值是回调的错误，参考 ShutdownSummary.Err
+func (app *App) Shutdown(ctx context.Context) error {
+	app.startShutdown()
+	select {
+	case <-app.done:
+		return app.summary.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done 关闭完成之后，返回的 channel 会被关闭
+func (app *App) Done() <-chan struct{} {
+	return app.done
+}
+
+// startShutdown 保证只关闭一次
+func (app *App) startShutdown() {
+	app.shutdownOnce.Do(func() {
+		close(app.stopping)
+		go func() {
+			app.shutdown()
+			close(app.done)
+		}()
+	})
 }
 
 // shutdown 你要设计这里面的执行步骤。
 func (app *App) shutdown() {
-	log.Println("开始关闭应用，停止接收新请求")
-	// 你需要在这里让所有的 server 拒绝新请求
-
-	var wg sync.WaitGroup
+	// 先让就绪检查失败，等负载均衡摘掉实例之后再拒绝请求
+	atomic.StoreInt32(&app.closing, 1)
+	if atomic.LoadInt32(&app.upgraded) != 0 {
+		// 子进程和当前进程在同一个 socket 上接收连接，这个时候拒绝请求的话，
+		// 被当前进程接收的连接会收到 503。所以先关闭监听，新的连接都交给子进程，
+		// 然后等待已经在处理的请求
+		log.Println("子进程已经接管，关闭监听，等待正在执行请求完结")
+		app.shutdownServers(true)
+		app.waitInFlight()
+	} else {
+		if app.readinessDelay > 0 {
+			log.Printf("就绪检查已经失败，%v 之后停止接收新请求", app.readinessDelay)
+			time.Sleep(app.readinessDelay)
+		}
+		log.Println("开始关闭应用，停止接收新请求")
+		// 你需要在这里让所有的 server 拒绝新请求
+		for _, s := range app.servers {
+			s.RejectNew()
+		}
 
-	lenServer := len(app.servers)
+		log.Println("等待正在执行请求完结")
+		// 在这里等待一段时间
+		app.waitInFlight()
 
-	wg.Add(lenServer)
-	for _, s := range app.servers {
-		s.rejectReq()
-		wg.Done()
+		log.Println("开始关闭服务器")
+		app.shutdownServers(false)
 	}
 
-	wg.Wait()
+	log.Println("开始执行自定义回调")
+	// 按阶段执行回调，同一个阶段的回调并发执行
+	app.summary = app.runCallbacks()
+	log.Println(app.summary)
 
-	log.Println("等待正在执行请求完结")
-	// 在这里等待一段时间
+	// 释放资源
+	log.Println("开始释放资源")
+
+	// 这一个步骤不需要你干什么，这是假装我们整个应用自己要释放一些资源
+	app.close()
+	//panic("实现前面的步骤")
+}
 
-	log.Println("开始关闭服务器")
-	// 并发关闭服务器，同时要注意协调所有的 server 都关闭之后才能步入下一个阶段
-	wg.Add(lenServer)
+// shutdownServers 并发关闭服务器，同时要注意协调所有的 server 都关闭之后才能步入下一个阶段
+// handoff 为 true 的时候，实现了 HandoffServer 的服务器先把连接交给子进程
+func (app *App) shutdownServers(handoff bool) {
+	var wg sync.WaitGroup
+	wg.Add(len(app.servers))
 	for _, s := range app.servers {
-		go func(s *Server) {
-			ctx, cancel := context.WithTimeout(context.Background(), app.cbTimeout)
+		go func(s Server) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), app.waitTime)
 			defer cancel()
-			s.stop(ctx)
-			wg.Done()
+			if h, ok := s.(HandoffServer); ok && handoff {
+				if err := h.Handoff(ctx); err != nil {
+					log.Printf("服务器%s交接失败 %v", s.Name(), err)
+				}
+			}
+			if err := s.Shutdown(ctx); err != nil {
+				log.Printf("服务器%s关闭失败 %v", s.Name(), err)
+			}
 		}(s)
 	}
 	wg.Wait()
+}
 
-	log.Println("开始执行自定义回调")
-	// 并发执行回调，要注意协调所有的回调都执行完才会步入下一个阶段
-	wg.Add(len(app.cbs))
+// Summary 返回关闭的汇总，在关闭完成之后调用
+func (app *App) Summary() ShutdownSummary {
+	return app.summary
+}
 
-	for _, cbs := range app.cbs {
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), app.cbTimeout)
-			defer cancel()
-			cbs(ctx)
-			wg.Done()
-		}()
+// waitInFlight 等待所有服务器正在处理的请求结束，最多等待 waitTime
+func (app *App) waitInFlight() {
+	deadline := time.NewTimer(app.waitTime)
+	defer deadline.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	lastLog := time.Now()
+	for {
+		total := app.InFlight()
+		if total == 0 {
+			log.Println("所有请求都已经处理完毕")
+			return
+		}
+		if time.Since(lastLog) >= time.Second {
+			lastLog = time.Now()
+			app.logCounts()
+		}
+		select {
+		case <-deadline.C:
+			log.Printf("等待超时，还有 %d 个请求没有处理完", total)
+			app.logCounts()
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	wg.Wait()
-
-	// 释放资源
-	log.Println("开始释放资源")
+// InFlight 所有服务器正在处理的请求数量之和
+func (app *App) InFlight() int64 {
+	var total int64
+	for _, s := range app.servers {
+		if c, ok := s.(InFlightCounter); ok {
+			total += c.InFlight()
+		}
+	}
+	return total
+}
 
-	// 这一个步骤不需要你干什么，这是假装我们整个应用自己要释放一些资源
-	app.close()
-	//panic("实现前面的步骤")
+func (app *App) logCounts() {
+	for _, s := range app.servers {
+		c, ok := s.(InFlightCounter)
+		if !ok {
+			continue
+		}
+		if r, ok := s.(interface{ Rejected() int64 }); ok {
+			log.Printf("服务器%s正在处理 %d 个请求，已拒绝 %d 个请求", s.Name(), c.InFlight(), r.Rejected())
+			continue
+		}
+		log.Printf("服务器%s正在处理 %d 个请求", s.Name(), c.InFlight())
+	}
 }
 
 func (app *App) close() {
@@ -162,23 +392,129 @@ func (app *App) close() {
 }
 
 // Server 本身可以是很多种 Server，例如 http server
-// 或者 RPC server
-// 理论上来说，如果你设计一个脚手架的框架，那么 Server 应该是一个接口
-type Server struct {
-	srv  *http.Server
-	name string
-	mux  *serverMux
+// 或者 RPC server，App 通过这个接口统一管理它们
+type Server interface {
+	Name() string
+	// Start 启动服务器，会一直阻塞，直到出错或者被关闭
+	Start() error
+	// RejectNew 拒绝新请求，已经在处理的请求不受影响
+	RejectNew()
+	// Shutdown 关闭服务器，等待正在处理的请求结束，ctx 超时之后不再等待
+	Shutdown(ctx context.Context) error
+}
+
+// ReadyServer 能够告诉 App 什么时候开始监听的服务器，
+// 没有实现的服务器调用 Start 之后就认为已经在监听了
+type ReadyServer interface {
+	Server
+	// StartWithReady 和 Start 一样，开始监听之后调用 ready
+	StartWithReady(ready func()) error
+}
+
+// HandoffServer 零停机重启的时候，能够把连接交给子进程的服务器
+// 没有实现的服务器直接调用 Shutdown，由 Shutdown 关闭监听并且等待请求结束
+type HandoffServer interface {
+	// Handoff 关闭监听，新的连接都由子进程接收，然后等待已经接收的连接上的请求处理完，
+	// 不会拒绝请求。ctx 超时之后不再等待
+	Handoff(ctx context.Context) error
+}
+
+// GracefulServer 可以优雅退出的服务器
+// web.HTTPServer 和 rpc.Server 都实现了这个接口，但是它们的 Start 需要监听地址，
+// 所以要通过 WrapServer 适配成 Server。它们也实现了 HandoffServer，零停机重启的时候不会拒绝请求
+type GracefulServer interface {
+	RejectNew()
+	Shutdown(ctx context.Context) error
+}
+
+// WrapServer 把 GracefulServer 适配成 Server，start 负责启动服务器，例如
+//
+//	WrapServer("web", func() error { return webSrv.Start(":8080") }, webSrv)
+func WrapServer(name string, start func() error, srv GracefulServer) Server {
+	return &wrappedServer{GracefulServer: srv, name: name, start: start}
+}
+
+type wrappedServer struct {
+	GracefulServer
+	name  string
+	start func() error
+}
+
+func (w *wrappedServer) Name() string {
+	return w.name
+}
+
+func (w *wrappedServer) Start() error {
+	return w.start()
+}
+
+// Handoff 被适配的服务器没有实现 HandoffServer 的时候什么也不做
+func (w *wrappedServer) Handoff(ctx context.Context) error {
+	if h, ok := w.GracefulServer.(HandoffServer); ok {
+		return h.Handoff(ctx)
+	}
+	return nil
+}
+
+// InFlight 被适配的服务器没有实现 InFlightCounter 的时候返回 0
+func (w *wrappedServer) InFlight() int64 {
+	if c, ok := w.GracefulServer.(InFlightCounter); ok {
+		return c.InFlight()
+	}
+	return 0
+}
+
+// InFlightCounter 能够统计正在处理的请求数量的服务器
+// App 关闭的时候会等待计数归零，没有实现的服务器只能依赖 Shutdown 自己等待
+type InFlightCounter interface {
+	InFlight() int64
+}
+
+var _ ReadyServer = &HTTPServer{}
+var _ HandoffServer = &HTTPServer{}
+var _ InFlightCounter = &HTTPServer{}
+
+// HTTPServer 基于 http.ServeMux 的 Server
+type HTTPServer struct {
+	srv       *http.Server
+	name      string
+	mux       *serverMux
+	listeners *Listeners
+
+	mutex    sync.Mutex
+	listener net.Listener
+	// conns 连接和它的状态，交接的时候等待新建和活跃的连接处理完
+	conns map[net.Conn]http.ConnState
 }
 
 // serverMux 既可以看做是装饰器模式，也可以看做委托模式
 type serverMux struct {
-	reject bool
+	// inFlight 和 rejected 放在最前面保证 64 位对齐
+	inFlight int64
+	// rejected 关闭过程中拒绝掉的请求数量
+	rejected int64
+	reject   int32
+	// retryAfter 拒绝请求的时候告诉客户端多少秒之后重试
+	retryAfter string
+	// probes 健康检查的路径
+	probes map[string]struct{}
 	*http.ServeMux
 }
 
 func (s *serverMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 只是在考虑到 CPU 高速缓存的时候，会存在短时间的不一致性
-	if s.reject {
+	// 健康检查不计数，也不会被拒绝
+	if _, ok := s.probes[r.URL.Path]; ok {
+		s.ServeMux.ServeHTTP(w, r)
+		return
+	}
+	// 先计数再检查，保证 App 看到计数为 0 的时候，不会再有请求开始执行
+	atomic.AddInt64(&s.inFlight, 1)
+	defer atomic.AddInt64(&s.inFlight, -1)
+	if atomic.LoadInt32(&s.reject) != 0 {
+		atomic.AddInt64(&s.rejected, 1)
+		// 让客户端断开连接，重新连接的时候会被负载均衡转发到别的实例上
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Retry-After", s.retryAfter)
 		w.WriteHeader(http.StatusServiceUnavailable)
 		_, _ = w.Write([]byte("服务已关闭"))
 		return
@@ -186,37 +522,147 @@ func (s *serverMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.ServeMux.ServeHTTP(w, r)
 }
 
-func NewServer(name string, addr string) *Server {
-	mux := &serverMux{ServeMux: http.NewServeMux()}
-	return &Server{
-		name: name,
-		mux:  mux,
-		srv: &http.Server{
-			Addr:    addr,
-			Handler: mux,
-		},
+type ServerOption func(server *HTTPServer)
+
+// ServerWithListeners 设置监听使用的 Listeners，默认是 DefaultListeners
+func ServerWithListeners(ls *Listeners) ServerOption {
+	return func(server *HTTPServer) {
+		server.listeners = ls
+	}
+}
+
+// ServerWithRetryAfter 设置拒绝请求的时候 Retry-After 的值，默认 5 秒
+func ServerWithRetryAfter(d time.Duration) ServerOption {
+	return func(server *HTTPServer) {
+		server.mux.retryAfter = strconv.Itoa(int(d.Seconds()))
+	}
+}
+
+func NewServer(name string, addr string, opts ...ServerOption) *HTTPServer {
+	mux := &serverMux{
+		ServeMux:   http.NewServeMux(),
+		retryAfter: "5",
+		probes:     make(map[string]struct{}, 2),
+	}
+	res := &HTTPServer{
+		name:  name,
+		mux:   mux,
+		conns: make(map[net.Conn]http.ConnState, 16),
+	}
+	res.srv = &http.Server{
+		Addr:      addr,
+		Handler:   mux,
+		ConnState: res.trackConn,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	if res.listeners == nil {
+		res.listeners = DefaultListeners()
 	}
+	return res
 }
 
-func (s *Server) Handle(pattern string, handler http.Handler) {
+func (s *HTTPServer) Handle(pattern string, handler http.Handler) {
 	s.mux.Handle(pattern, handler)
 }
 
-func (s *Server) Start() error {
-	return s.srv.ListenAndServe()
+// handleProbe 注册健康检查，关闭过程中也能访问
+func (s *HTTPServer) handleProbe(pattern string, handler http.Handler) {
+	s.mux.probes[pattern] = struct{}{}
+	s.mux.Handle(pattern, handler)
 }
 
-func (s *Server) rejectReq() {
-	s.mux.reject = true
+func (s *HTTPServer) Name() string {
+	return s.name
 }
 
-func (s *Server) stop(ctx context.Context) error {
-	log.Printf("服务器%s关闭中", s.name)
+// Start 通过 Listeners 监听，零停机重启的时候会沿用父进程的 socket
+func (s *HTTPServer) Start() error {
+	return s.StartWithReady(func() {})
+}
 
-	// 在这里模拟停下服务器
-	time.Sleep(1 * time.Second)
-	s.srv.Shutdown(ctx)
-	//panic("implement me")
+// StartWithReady 监听成功之后调用 ready，这个时候新的连接已经可以进入 backlog 了
+func (s *HTTPServer) StartWithReady(ready func()) error {
+	addr := s.srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := s.listeners.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.listener = l
+	s.mutex.Unlock()
+	ready()
+	return s.srv.Serve(l)
+}
 
+// Handoff 关闭监听之后，已经接收但是还没有读到请求的连接，
+// 在 http.Server.Shutdown 里面会被直接断开，所以要等它们处理完再关闭
+func (s *HTTPServer) Handoff(ctx context.Context) error {
+	// 处理完当前的请求就断开连接，客户端重新连接的时候由子进程接收
+	s.srv.SetKeepAlivesEnabled(false)
+	s.mutex.Lock()
+	l := s.listener
+	s.mutex.Unlock()
+	if l != nil {
+		if err := l.Close(); err != nil {
+			return err
+		}
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for s.busyConns() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 	return nil
 }
+
+func (s *HTTPServer) trackConn(conn net.Conn, state http.ConnState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state == http.StateClosed || state == http.StateHijacked {
+		delete(s.conns, conn)
+		return
+	}
+	s.conns[conn] = state
+}
+
+// busyConns 新建和活跃的连接数量，空闲的连接会被 Shutdown 关闭
+func (s *HTTPServer) busyConns() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := 0
+	for _, state := range s.conns {
+		if state == http.StateNew || state == http.StateActive {
+			res++
+		}
+	}
+	return res
+}
+
+func (s *HTTPServer) RejectNew() {
+	atomic.StoreInt32(&s.mux.reject, 1)
+}
+
+// InFlight 正在处理的请求数量
+func (s *HTTPServer) InFlight() int64 {
+	return atomic.LoadInt64(&s.mux.inFlight)
+}
+
+// Rejected 关闭过程中拒绝掉的请求数量
+func (s *HTTPServer) Rejected() int64 {
+	return atomic.LoadInt64(&s.mux.rejected)
+}
+
+// Shutdown 关闭监听，http.Server.Shutdown 会等待正在处理的请求结束
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	log.Printf("服务器%s关闭中", s.name)
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_Signal(t *testing.T) {
+	srv := newMockServer("mock")
+	var mutex sync.Mutex
+	var order []string
+	record := func(name string) ShutdownCallbackE {
+		return func(ctx context.Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			// 回调执行的时候，服务器已经关闭了
+			assert.True(t, srv.isShutdown())
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	app := NewApp([]Server{srv},
+		WithSignalChannel(signals),
+		WithExitFunc(func(code int) {
+			t.Errorf("不应该强制退出 %d", code)
+		}),
+		WithWaitTime(time.Second),
+		WithShutdownCallbackE("close-db", record("close-db"), CallbackWithPhase(PhaseClose)),
+		WithShutdownCallbackE("flush", record("flush"), CallbackWithPhase(PhaseFlush)),
+		WithShutdownCallbacks(func(ctx context.Context) {
+			_ = record("default")(ctx)
+		}),
+		WithShutdownCallbackE("failed", func(ctx context.Context) error {
+			return errors.New("mock error")
+		}),
+	)
+	finished := make(chan struct{})
+	go func() {
+		app.StartAndServe()
+		close(finished)
+	}()
+	<-srv.started
+
+	signals <- syscall.SIGTERM
+	select {
+	case <-app.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("关闭超时")
+	}
+	<-finished
+
+	assert.True(t, srv.isRejected())
+	assert.True(t, srv.isShutdown())
+	assert.Equal(t, []string{"flush", "default", "close-db"}, order)
+
+	summary := app.Summary()
+	require.Len(t, summary.Callbacks, 4)
+	assert.EqualError(t, summary.Err(), "service: 1 个回调执行失败 [failed: mock error]")
+}
+
+func TestApp_Shutdown(t *testing.T) {
+	srv := NewServer("business", "127.0.0.1:0", ServerWithRetryAfter(3*time.Second))
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	srv.Handle("/slow", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		close(started)
+		<-finish
+		_, _ = writer.Write([]byte("done"))
+	}))
+	app := NewApp([]Server{srv}, WithSignalChannel(make(chan os.Signal)), WithWaitTime(time.Second))
+	go app.StartAndServe()
+
+	// 直接调用 mux，模拟一个正在执行的请求
+	slowRecorder := httptest.NewRecorder()
+	slowDone := make(chan struct{})
+	go func() {
+		srv.mux.ServeHTTP(slowRecorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(slowDone)
+	}()
+	<-started
+	assert.Equal(t, int64(1), srv.InFlight())
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- app.Shutdown(context.Background())
+	}()
+
+	// 关闭中的新请求会被拒绝
+	require.Eventually(t, func() bool {
+		recorder := httptest.NewRecorder()
+		srv.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		if recorder.Code != http.StatusServiceUnavailable {
+			return false
+		}
+		assert.Equal(t, "close", recorder.Header().Get("Connection"))
+		assert.Equal(t, "3", recorder.Header().Get("Retry-After"))
+		return true
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int64(1), srv.Rejected())
+
+	// 正在执行的请求没有结束，不会进入下一个阶段
+	select {
+	case <-app.Done():
+		t.Fatal("没有等待正在执行的请求")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(finish)
+	<-slowDone
+	assert.Equal(t, "done", slowRecorder.Body.String())
+	assert.NoError(t, <-shutdownErr)
+	assert.Equal(t, int64(0), srv.InFlight())
+
+	// 重复调用直接返回
+	assert.NoError(t, app.Shutdown(context.Background()))
+}
+
+func TestApp_Shutdown_ContextTimeout(t *testing.T) {
+	app := NewApp([]Server{newMockServer("mock")},
+		WithSignalChannel(make(chan os.Signal)),
+		WithShutdownCallbackE("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, CallbackWithTimeout(200*time.Millisecond)))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, app.Shutdown(ctx))
+
+	// 关闭过程还在后台继续，回调超时被记录下来
+	<-app.Done()
+	summary := app.Summary()
+	require.Len(t, summary.Callbacks, 1)
+	assert.Equal(t, context.DeadlineExceeded, summary.Callbacks[0].Err)
+}
+
+func TestApp_Exit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		signals  int
+		wantCode int
+	}{
+		{
+			// 第二次收到信号立刻退出
+			name:     "second signal",
+			signals:  2,
+			wantCode: 1,
+		},
+		{
+			name:     "timeout",
+			signals:  1,
+			wantCode: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			signals := make(chan os.Signal, 2)
+			exitCode := make(chan int, 1)
+			block := make(chan struct{})
+			defer close(block)
+			srv := newMockServer("mock")
+			app := NewApp([]Server{srv},
+				WithSignalChannel(signals),
+				WithShutdownTimeout(100*time.Millisecond),
+				WithExitFunc(func(code int) {
+					exitCode <- code
+				}),
+				// 回调一直不结束，只能强制退出
+				WithShutdownCallbackE("block", func(ctx context.Context) error {
+					<-block
+					return nil
+				}, CallbackWithTimeout(time.Minute)))
+			go app.StartAndServe()
+			<-srv.started
+
+			for i := 0; i < tc.signals; i++ {
+				signals <- syscall.SIGINT
+			}
+			select {
+			case code := <-exitCode:
+				assert.Equal(t, tc.wantCode, code)
+			case <-time.After(5 * time.Second):
+				t.Fatal("没有强制退出")
+			}
+		})
+	}
+}
+
+func TestWrapServer(t *testing.T) {
+	mock := newMockServer("inner")
+	srv := WrapServer("wrapped", mock.Start, mock)
+	assert.Equal(t, "wrapped", srv.Name())
+	c, ok := srv.(InFlightCounter)
+	require.True(t, ok)
+	assert.Equal(t, int64(0), c.InFlight())
+
+	go func() {
+		_ = srv.Start()
+	}()
+	<-mock.started
+	srv.RejectNew()
+	require.NoError(t, srv.Shutdown(context.Background()))
+	assert.True(t, mock.isRejected())
+	assert.True(t, mock.isShutdown())
+}
+
+type mockServer struct {
+	name    string
+	started chan struct{}
+	stopped chan struct{}
+
+	mutex    sync.Mutex
+	rejected bool
+	shutdown bool
+}
+
+func newMockServer(name string) *mockServer {
+	return &mockServer{
+		name:    name,
+		started: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (m *mockServer) Name() string {
+	return m.name
+}
+
+func (m *mockServer) Start() error {
+	close(m.started)
+	<-m.stopped
+	return http.ErrServerClosed
+}
+
+// StartWithReady 启动之后立刻就在监听
+func (m *mockServer) StartWithReady(ready func()) error {
+	ready()
+	return m.Start()
+}
+
+func (m *mockServer) RejectNew() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rejected = true
+}
+
+func (m *mockServer) Shutdown(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.shutdown {
+		m.shutdown = true
+		close(m.stopped)
+	}
+	return nil
+}
+
+func (m *mockServer) isRejected() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.rejected
+}
+
+func (m *mockServer) isShutdown() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.shutdown
+}
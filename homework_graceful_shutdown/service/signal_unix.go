@@ -0,0 +1,11 @@
+//go:build !windows
+
+package service
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals 收到这些信号的时候零停机重启
+var upgradeSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
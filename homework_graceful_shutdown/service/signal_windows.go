@@ -0,0 +1,6 @@
+package service
+
+import "os"
+
+// upgradeSignals Windows 不支持传递 socket，所以不支持零停机重启
+var upgradeSignals []os.Signal
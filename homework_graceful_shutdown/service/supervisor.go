@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+var errServerExited = errors.New("service: 服务器意外退出")
+
+// FailurePolicy 服务器在运行过程中退出的时候怎么处理
+type FailurePolicy int
+
+const (
+	// PolicyFail 关闭整个应用，这是默认的策略
+	PolicyFail FailurePolicy = iota
+	// PolicyRestart 按照退避策略重启，重启次数超过限制之后关闭整个应用
+	PolicyRestart
+	// PolicyIgnore 只记录错误，其它服务器继续运行
+	PolicyIgnore
+)
+
+// ServerError 服务器异常退出的错误
+type ServerError struct {
+	Server string
+	Err    error
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("service: 服务器%s异常退出 %v", e.Server, e.Err)
+}
+
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+// StartupHook 在服务器启动之前执行，例如执行数据库迁移、预热缓存
+type StartupHook func(ctx context.Context) error
+
+type startupHook struct {
+	name string
+	fn   StartupHook
+}
+
+// WithServerPolicy 设置名字为 name 的服务器的失败策略
+func WithServerPolicy(name string, policy FailurePolicy) Option {
+	return func(a *App) {
+		a.policies[name] = policy
+	}
+}
+
+// WithRestartBackoff 设置重启的退避时间，每次重启之后翻倍，最多是 max
+// maxRestarts 是连续重启的最多次数，为 0 说明不限制
+// 服务器运行一段时间之后才退出的，不算连续重启，参考 WithRestartResetAfter
+func WithRestartBackoff(initial time.Duration, max time.Duration, maxRestarts int) Option {
+	return func(a *App) {
+		a.backoffInitial = initial
+		a.backoffMax = max
+		a.maxRestarts = maxRestarts
+	}
+}
+
+// WithRestartResetAfter 服务器运行超过 d 之后才退出，说明重启成功了，
+// 重启次数和退避时间都重新计算。默认是退避时间的最大值
+func WithRestartResetAfter(d time.Duration) Option {
+	return func(a *App) {
+		a.restartResetAfter = d
+	}
+}
+
+// WithStartupHook 注册启动钩子，按照注册顺序依次执行，任何一个失败应用都不会启动
+func WithStartupHook(name string, hook StartupHook) Option {
+	return func(a *App) {
+		a.startupHooks = append(a.startupHooks, startupHook{name: name, fn: hook})
+	}
+}
+
+// WithStartupTimeout 设置所有启动钩子加起来的超时时间，默认 30 秒
+func WithStartupTimeout(timeout time.Duration) Option {
+	return func(a *App) {
+		a.startupTimeout = timeout
+	}
+}
+
+// Errors 服务器异常退出和启动失败的错误会发到这个 channel 上
+// channel 满了之后新的错误会被丢弃，所以需要及时读取
+func (app *App) Errors() <-chan error {
+	return app.errs
+}
+
+// Err 导致应用关闭的错误，收到信号或者主动关闭的时候是 nil
+func (app *App) Err() error {
+	app.errMutex.Lock()
+	defer app.errMutex.Unlock()
+	return app.err
+}
+
+// startup 执行启动钩子
+func (app *App) startup() error {
+	if len(app.startupHooks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), app.startupTimeout)
+	defer cancel()
+	for _, h := range app.startupHooks {
+		log.Printf("执行启动钩子%s", h.name)
+		done := make(chan error, 1)
+		go func(h startupHook) {
+			done <- h.fn(ctx)
+		}(h)
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("service: 启动钩子%s执行失败 %w", h.name, err)
+		}
+	}
+	return nil
+}
+
+// supervise 启动服务器，并且按照失败策略处理退出
+func (app *App) supervise(srv Server) {
+	policy := app.policies[srv.Name()]
+	backoff := app.backoffInitial
+	resetAfter := app.restartResetAfter
+	if resetAfter <= 0 {
+		resetAfter = app.backoffMax
+	}
+	// restarts 连续重启的次数
+	restarts := 0
+	for {
+		start := time.Now()
+		err := app.start(srv)
+		// 不同的服务器关闭之后返回的错误不一样，例如 http.ErrServerClosed，
+		// 所以只要是在关闭中退出的，就认为是正常关闭
+		if atomic.LoadInt32(&app.closing) != 0 {
+			log.Printf("服务器%s已关闭", srv.Name())
+			return
+		}
+		if err == nil {
+			err = errServerExited
+		}
+		serr := &ServerError{Server: srv.Name(), Err: err}
+
+		if policy == PolicyIgnore {
+			log.Println(serr)
+			app.emit(serr)
+			return
+		}
+		// 运行了足够长的时间才退出，不是连续的失败
+		if time.Since(start) >= resetAfter {
+			restarts, backoff = 0, app.backoffInitial
+		}
+		if policy == PolicyRestart && (app.maxRestarts <= 0 || restarts < app.maxRestarts) {
+			restarts++
+			log.Printf("%v，%v 之后重启", serr, backoff)
+			app.emit(serr)
+			select {
+			case <-time.After(backoff):
+			case <-app.stopping:
+				return
+			}
+			backoff *= 2
+			if backoff > app.backoffMax {
+				backoff = app.backoffMax
+			}
+			continue
+		}
+		app.fail(serr)
+		return
+	}
+}
+
+// start 启动服务器，开始监听的时候计入正在监听的服务器，退出的时候减掉
+func (app *App) start(srv Server) error {
+	// 0 还没有开始监听，1 正在监听，2 已经退出
+	var state int32
+	ready := func() {
+		if atomic.CompareAndSwapInt32(&state, 0, 1) &&
+			atomic.AddInt32(&app.listening, 1) == int32(len(app.servers)) {
+			app.markServing()
+		}
+	}
+	var err error
+	if rs, ok := srv.(ReadyServer); ok {
+		err = rs.StartWithReady(ready)
+	} else {
+		// 不知道什么时候开始监听，只能认为调用 Start 就已经在监听了
+		ready()
+		err = srv.Start()
+	}
+	if atomic.SwapInt32(&state, 2) == 1 {
+		atomic.AddInt32(&app.listening, -1)
+	}
+	return err
+}
+
+// fail 记录错误，并且关闭整个应用
+func (app *App) fail(err error) {
+	log.Println(err)
+	app.errMutex.Lock()
+	if app.err == nil {
+		app.err = err
+	}
+	app.errMutex.Unlock()
+	app.emit(err)
+	app.startShutdown()
+}
+
+func (app *App) emit(err error) {
+	select {
+	case app.errs <- err:
+	default:
+		log.Printf("错误 channel 已满，丢弃错误 %v", err)
+	}
+}
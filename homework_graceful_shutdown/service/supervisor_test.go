@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_ServerPolicy(t *testing.T) {
+	bindErr := errors.New("bind: address already in use")
+	testCases := []struct {
+		name   string
+		policy FailurePolicy
+		// failures 前几次启动会失败
+		failures int
+
+		wantErr    error
+		wantStarts int
+		// wantReady 没有关闭的时候是否就绪
+		wantReady bool
+	}{
+		{
+			name:       "fail",
+			policy:     PolicyFail,
+			failures:   1,
+			wantErr:    &ServerError{Server: "flaky", Err: bindErr},
+			wantStarts: 1,
+		},
+		{
+			name:       "restart",
+			policy:     PolicyRestart,
+			failures:   2,
+			wantStarts: 3,
+			wantReady:  true,
+		},
+		{
+			// 超过重启次数之后关闭整个应用
+			name:       "restart exceeded",
+			policy:     PolicyRestart,
+			failures:   5,
+			wantErr:    &ServerError{Server: "flaky", Err: bindErr},
+			wantStarts: 4,
+		},
+		{
+			// 没有在监听的服务器，就绪检查要失败
+			name:       "ignore",
+			policy:     PolicyIgnore,
+			failures:   1,
+			wantStarts: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flaky := newFlakyServer("flaky", tc.failures, bindErr)
+			healthy := newMockServer("healthy")
+			app := NewApp([]Server{flaky, healthy},
+				WithSignalChannel(make(chan os.Signal)),
+				WithServerPolicy("flaky", tc.policy),
+				WithRestartBackoff(time.Millisecond, 4*time.Millisecond, 3),
+				WithRestartResetAfter(time.Minute))
+			go app.StartAndServe()
+
+			select {
+			case err := <-app.Errors():
+				assert.Equal(t, &ServerError{Server: "flaky", Err: bindErr}, err)
+			case <-time.After(time.Second):
+				t.Fatal("没有收到错误")
+			}
+
+			if tc.wantErr != nil {
+				select {
+				case <-app.Done():
+				case <-time.After(5 * time.Second):
+					t.Fatal("应用没有关闭")
+				}
+				assert.Equal(t, tc.wantErr, app.Err())
+				assert.True(t, healthy.isShutdown())
+				assert.Equal(t, tc.wantStarts, flaky.startCount())
+				return
+			}
+
+			require.Eventually(t, func() bool {
+				return flaky.startCount() == tc.wantStarts
+			}, time.Second, time.Millisecond)
+			// 其它服务器继续运行
+			select {
+			case <-app.Done():
+				t.Fatal("应用不应该关闭")
+			case <-time.After(50 * time.Millisecond):
+			}
+			assert.False(t, healthy.isShutdown())
+			assert.Equal(t, tc.wantReady, app.Ready())
+			require.NoError(t, app.Shutdown(context.Background()))
+			assert.False(t, app.Ready())
+			assert.Nil(t, app.Err())
+			assert.Equal(t, tc.wantStarts, flaky.startCount())
+		})
+	}
+}
+
+// TestApp_RestartReset 运行了一段时间之后才崩溃的，不算连续重启
+func TestApp_RestartReset(t *testing.T) {
+	crashErr := errors.New("mock crash")
+	// 启动之后立刻崩溃，重启之后运行一段时间再崩溃，然后又立刻崩溃
+	srv := &crashServer{mockServer: newMockServer("crash"), err: crashErr,
+		runs: []time.Duration{0, 100 * time.Millisecond, 0}}
+	app := NewApp([]Server{srv},
+		WithSignalChannel(make(chan os.Signal)),
+		WithServerPolicy("crash", PolicyRestart),
+		WithRestartBackoff(time.Millisecond, 4*time.Millisecond, 1),
+		WithRestartResetAfter(50*time.Millisecond))
+	go app.StartAndServe()
+
+	select {
+	case <-app.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("应用没有关闭")
+	}
+	// 第二次崩溃之前运行的时间足够长，重启次数重新计算，所以还能再重启一次
+	assert.Equal(t, 3, srv.startCount())
+	assert.Equal(t, &ServerError{Server: "crash", Err: crashErr}, app.Err())
+}
+
+func TestApp_StartupHook(t *testing.T) {
+	testCases := []struct {
+		name    string
+		hooks   []StartupHook
+		wantErr string
+	}{
+		{
+			name: "success",
+			hooks: []StartupHook{
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return nil },
+			},
+		},
+		{
+			name: "error",
+			hooks: []StartupHook{
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return errors.New("mock error") },
+			},
+			wantErr: "service: 启动钩子hook-1执行失败 mock error",
+		},
+		{
+			name: "timeout",
+			hooks: []StartupHook{
+				func(ctx context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				},
+			},
+			wantErr: "service: 启动钩子hook-0执行失败 context deadline exceeded",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newMockServer("mock")
+			opts := []Option{
+				WithSignalChannel(make(chan os.Signal)),
+				WithStartupTimeout(100 * time.Millisecond),
+			}
+			for i, h := range tc.hooks {
+				opts = append(opts, WithStartupHook(fmt.Sprintf("hook-%d", i), h))
+			}
+			app := NewApp([]Server{srv}, opts...)
+			go app.StartAndServe()
+
+			if tc.wantErr != "" {
+				<-app.Done()
+				assert.EqualError(t, app.Err(), tc.wantErr)
+				// 服务器没有启动
+				select {
+				case <-srv.started:
+					t.Fatal("启动钩子失败的时候不应该启动服务器")
+				default:
+				}
+				return
+			}
+			<-srv.started
+			require.Eventually(t, app.Ready, time.Second, time.Millisecond)
+			require.NoError(t, app.Shutdown(context.Background()))
+		})
+	}
+}
+
+// flakyServer 前 failures 次启动直接返回 err
+type flakyServer struct {
+	*mockServer
+	failures int
+	err      error
+
+	mutex  sync.Mutex
+	starts int
+}
+
+func newFlakyServer(name string, failures int, err error) *flakyServer {
+	return &flakyServer{mockServer: newMockServer(name), failures: failures, err: err}
+}
+
+func (f *flakyServer) Start() error {
+	return f.StartWithReady(func() {})
+}
+
+// StartWithReady 失败的时候没有开始监听
+func (f *flakyServer) StartWithReady(ready func()) error {
+	f.mutex.Lock()
+	f.starts++
+	fail := f.starts <= f.failures
+	f.mutex.Unlock()
+	if fail {
+		return f.err
+	}
+	return f.mockServer.StartWithReady(ready)
+}
+
+func (f *flakyServer) startCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.starts
+}
+
+// crashServer 第 i 次启动之后运行 runs[i] 就崩溃，runs 用完之后一直运行
+type crashServer struct {
+	*mockServer
+	runs []time.Duration
+	err  error
+
+	mutex  sync.Mutex
+	starts int
+}
+
+func (c *crashServer) Start() error {
+	return c.StartWithReady(func() {})
+}
+
+func (c *crashServer) StartWithReady(ready func()) error {
+	c.mutex.Lock()
+	c.starts++
+	i := c.starts - 1
+	c.mutex.Unlock()
+	if i >= len(c.runs) {
+		return c.mockServer.StartWithReady(ready)
+	}
+	ready()
+	time.Sleep(c.runs[i])
+	return c.err
+}
+
+func (c *crashServer) startCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.starts
+}
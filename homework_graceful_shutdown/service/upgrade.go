@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var errUpgrading = errors.New("service: 正在重启")
+
+// WithListeners 设置重启的时候要交给子进程的 Listeners，默认是 DefaultListeners
+func WithListeners(ls *Listeners) Option {
+	return func(a *App) {
+		a.listeners = ls
+	}
+}
+
+// WithUpgradeCommand 设置重启的时候启动子进程的命令，默认用同样的参数重新执行当前程序
+func WithUpgradeCommand(fn func() *exec.Cmd) Option {
+	return func(a *App) {
+		a.upgradeCmd = fn
+	}
+}
+
+// WithUpgradeTimeout 设置等待子进程就绪的时间，默认 30 秒，超时之后杀掉子进程，父进程继续运行
+func WithUpgradeTimeout(timeout time.Duration) Option {
+	return func(a *App) {
+		a.upgradeTimeout = timeout
+	}
+}
+
+func defaultUpgradeCommand() *exec.Cmd {
+	return exec.Command(os.Args[0], os.Args[1:]...)
+}
+
+// Upgrade 零停机重启：启动子进程并且把监听的 socket 交给它，
+// 子进程的服务器都在监听之后，父进程关闭自己的监听，等待正在处理的请求结束之后退出，
+// 不会拒绝请求。收到 SIGHUP 或者 SIGUSR2 的时候也会调用
+// 子进程启动失败的时候父进程继续运行
+func (app *App) Upgrade() error {
+	if !atomic.CompareAndSwapInt32(&app.upgrading, 0, 1) {
+		return errUpgrading
+	}
+	err := app.upgrade()
+	if err != nil {
+		atomic.StoreInt32(&app.upgrading, 0)
+		return err
+	}
+	atomic.StoreInt32(&app.upgraded, 1)
+	app.startShutdown()
+	return nil
+}
+
+func (app *App) upgrade() error {
+	keys, files, err := app.listeners.files()
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd := app.upgradeCmd()
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	// 重复的环境变量以后面的为准
+	cmd.Env = append(cmd.Env,
+		envListeners+"="+strings.Join(keys, ","),
+		envReadyFD+"="+strconv.Itoa(firstExtraFD+len(files)))
+	cmd.ExtraFiles = append(files, w)
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	log.Printf("启动子进程，交接 %d 个 listener", len(files))
+	err = cmd.Start()
+	// 传递文件描述符的时候会把 socket 改成阻塞模式，父子进程共享这个设置，
+	// 阻塞模式下关闭 listener 不能让正在等待的 Accept 返回，所以要改回来
+	setNonblock(files)
+	// 子进程已经有了一份，父进程不关掉的话，子进程退出的时候读不到 EOF
+	_ = w.Close()
+	if err != nil {
+		return err
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		var bs [1]byte
+		_, er := r.Read(bs[:])
+		ready <- er
+	}()
+	exited := make(chan error, 1)
+	go func() {
+		// 回收子进程，父进程退出之后子进程会被 init 接管
+		exited <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(app.upgradeTimeout)
+	defer timer.Stop()
+	select {
+	case err = <-ready:
+		if err == nil {
+			log.Printf("子进程 %d 已经就绪，开始关闭当前进程", cmd.Process.Pid)
+			return nil
+		}
+		err = fmt.Errorf("service: 子进程没有就绪 %w", err)
+	case er := <-exited:
+		err = fmt.Errorf("service: 子进程退出 %v", er)
+	case <-timer.C:
+		err = errors.New("service: 等待子进程就绪超时")
+	}
+	_ = cmd.Process.Kill()
+	log.Println(err)
+	return err
+}
+
+// markServing 所有的服务器都在监听了
+func (app *App) markServing() {
+	app.servingOnce.Do(func() {
+		close(app.serving)
+	})
+}
+
+// notifyParentWhenServing 服务器都在监听之后才通知父进程，
+// 没有等到就开始关闭的话不通知，父进程等待超时之后继续运行
+func (app *App) notifyParentWhenServing() {
+	select {
+	case <-app.serving:
+		notifyParent()
+	case <-app.stopping:
+	}
+}
+
+// notifyParent 如果是由父进程启动的，通知父进程已经就绪
+func notifyParent() {
+	val := os.Getenv(envReadyFD)
+	if val == "" {
+		return
+	}
+	_ = os.Unsetenv(envReadyFD)
+	fd, err := strconv.Atoi(val)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	_, _ = f.Write([]byte{1})
+	_ = f.Close()
+}
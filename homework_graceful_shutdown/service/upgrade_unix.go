@@ -0,0 +1,15 @@
+//go:build !windows
+
+package service
+
+import (
+	"os"
+	"syscall"
+)
+
+// setNonblock 把文件描述符改回非阻塞模式
+func setNonblock(files []*os.File) {
+	for _, f := range files {
+		_ = syscall.SetNonblock(int(f.Fd()), true)
+	}
+}
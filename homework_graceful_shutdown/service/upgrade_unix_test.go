@@ -0,0 +1,253 @@
+//go:build !windows
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	web "myhomework/homework2"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	envUpgradeChild = "GRACEFUL_TEST_UPGRADE_CHILD"
+	// envUpgradeWeb web.HTTPServer 的监听地址
+	envUpgradeWeb = "GRACEFUL_TEST_UPGRADE_WEB"
+	// envUpgradeMode 为 notready 的时候，子进程的服务器启动失败，一直不会就绪
+	envUpgradeMode = "GRACEFUL_TEST_UPGRADE_MODE"
+)
+
+func TestApp_Upgrade(t *testing.T) {
+	ls := NewListeners()
+	// 先拿到空闲的端口，父子进程都用这些地址监听
+	addr, webAddr := freeAddr(t), freeAddr(t)
+
+	parent := NewServer("parent", addr, ServerWithListeners(ls))
+	parent.Handle("/", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte("parent"))
+	}))
+	parent.Handle("/slow", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		_, _ = writer.Write([]byte("parent"))
+	}))
+	// web.HTTPServer 通过 WrapServer 适配，也要交接而不是拒绝请求
+	parentWeb := web.NewHTTPServer()
+	parentWeb.Get("/", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("parent")
+	})
+	signals := make(chan os.Signal, 1)
+	app := NewApp([]Server{parent, wrapWeb(ls, webAddr, parentWeb)},
+		WithSignalChannel(signals),
+		WithListeners(ls),
+		WithWaitTime(2*time.Second),
+		WithUpgradeTimeout(10*time.Second),
+		WithUpgradeCommand(func() *exec.Cmd {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestApp_UpgradeChild$")
+			cmd.Env = append(os.Environ(), envUpgradeChild+"="+addr, envUpgradeWeb+"="+webAddr)
+			return cmd
+		}))
+	go app.StartAndServe()
+	require.Eventually(t, func() bool {
+		body, er := get(addr, "/")
+		webBody, webErr := get(webAddr, "/")
+		return er == nil && body == "parent" && webErr == nil && webBody == "parent"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 重启之前开始的请求，父进程要处理完
+	slow := make(chan string, 1)
+	go func() {
+		body, er := get(addr, "/slow")
+		if er != nil {
+			body = er.Error()
+		}
+		slow <- body
+	}()
+	require.Eventually(t, func() bool {
+		return parent.InFlight() == 1
+	}, time.Second, time.Millisecond)
+
+	// 重启的过程中一直有新的请求，父子进程都不能拒绝
+	stop := make(chan struct{})
+	failures := make(chan string, 2)
+	var wg sync.WaitGroup
+	for _, a := range []string{addr, webAddr} {
+		wg.Add(1)
+		go func(a string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				body, er := get(a, "/")
+				if er != nil {
+					body = er.Error()
+				}
+				if body != "parent" && body != "child" {
+					failures <- a + " " + body
+					return
+				}
+			}
+		}(a)
+	}
+
+	signals <- syscall.SIGHUP
+	select {
+	case <-app.Done():
+	case <-time.After(20 * time.Second):
+		t.Fatal("父进程没有退出")
+	}
+	close(stop)
+	wg.Wait()
+	close(failures)
+	for body := range failures {
+		t.Errorf("重启过程中的请求失败 %s", body)
+	}
+	assert.Nil(t, app.Err())
+	assert.Equal(t, int64(0), parent.Rejected())
+	assert.Equal(t, int64(0), parentWeb.Rejected())
+	assert.Equal(t, "parent", <-slow)
+
+	// 父进程退出之后，同一个地址由子进程继续处理
+	body, err := get(addr, "/")
+	require.NoError(t, err)
+	assert.Equal(t, "child", body)
+	body, err = get(webAddr, "/")
+	require.NoError(t, err)
+	assert.Equal(t, "child", body)
+	_, _ = get(addr, "/stop")
+}
+
+func TestApp_Upgrade_ChildNotServing(t *testing.T) {
+	ls := NewListeners()
+	l, err := ls.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	app := NewApp([]Server{newMockServer("mock")},
+		WithSignalChannel(make(chan os.Signal)),
+		WithListeners(ls),
+		WithUpgradeTimeout(time.Second),
+		WithUpgradeCommand(func() *exec.Cmd {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestApp_UpgradeChild$")
+			cmd.Env = append(os.Environ(),
+				envUpgradeChild+"="+l.Addr().String(), envUpgradeMode+"=notready")
+			return cmd
+		}))
+	// 子进程的服务器没有在监听，不能通知父进程退出
+	assert.Error(t, app.Upgrade())
+	select {
+	case <-app.Done():
+		t.Fatal("父进程不应该退出")
+	default:
+	}
+}
+
+func TestApp_Upgrade_ChildFailed(t *testing.T) {
+	ls := NewListeners()
+	_, err := ls.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	app := NewApp([]Server{newMockServer("mock")},
+		WithSignalChannel(make(chan os.Signal)),
+		WithListeners(ls),
+		WithUpgradeCommand(func() *exec.Cmd {
+			// 子进程没有通知就绪就退出了
+			return exec.Command("true")
+		}))
+	assert.Error(t, app.Upgrade())
+	// 父进程继续运行，可以再次重启
+	select {
+	case <-app.Done():
+		t.Fatal("父进程不应该退出")
+	default:
+	}
+	assert.Equal(t, int32(0), app.upgrading)
+}
+
+// TestApp_UpgradeChild 被 TestApp_Upgrade 作为子进程启动
+func TestApp_UpgradeChild(t *testing.T) {
+	addr := os.Getenv(envUpgradeChild)
+	if addr == "" {
+		t.Skip("只在零停机重启的测试里面作为子进程运行")
+	}
+	ls := NewListeners()
+	if os.Getenv(envUpgradeMode) == "notready" {
+		flaky := newFlakyServer("flaky", 1, errors.New("bind: address already in use"))
+		app := NewApp([]Server{flaky},
+			WithSignalChannel(make(chan os.Signal)),
+			WithListeners(ls),
+			WithServerPolicy("flaky", PolicyIgnore))
+		go func() {
+			time.Sleep(20 * time.Second)
+			_ = app.Shutdown(context.Background())
+		}()
+		app.StartAndServe()
+		return
+	}
+	child := NewServer("child", addr, ServerWithListeners(ls))
+	childWeb := web.NewHTTPServer()
+	childWeb.Get("/", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("child")
+	})
+	app := NewApp([]Server{child, wrapWeb(ls, os.Getenv(envUpgradeWeb), childWeb)},
+		WithSignalChannel(make(chan os.Signal)), WithListeners(ls))
+	child.Handle("/", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte("child"))
+	}))
+	child.Handle("/stop", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		go func() {
+			_ = app.Shutdown(context.Background())
+		}()
+	}))
+	go func() {
+		time.Sleep(20 * time.Second)
+		_ = app.Shutdown(context.Background())
+	}()
+	app.StartAndServe()
+}
+
+// wrapWeb 通过 Listeners 监听，子进程会沿用父进程的 socket
+func wrapWeb(ls *Listeners, addr string, srv *web.HTTPServer) Server {
+	return WrapServer("web", func() error {
+		l, err := ls.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		return srv.Serve(l)
+	}, srv)
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func get(addr string, path string) (string, error) {
+	client := &http.Client{
+		Timeout: time.Second,
+		// 每次都建立新的连接，才能确定是哪个进程在监听
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	resp, err := client.Get("http://" + addr + path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(resp.Body)
+	return string(bs), err
+}
@@ -0,0 +1,6 @@
+package service
+
+import "os"
+
+// setNonblock Windows 不支持零停机重启，什么也不做
+func setNonblock(files []*os.File) {}
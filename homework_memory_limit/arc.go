@@ -0,0 +1,125 @@
+package cache
+
+import "container/list"
+
+var _ EvictionPolicy = &ARCPolicy{}
+
+const (
+	arcT1 = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+// ARCPolicy 自适应替换缓存（Adaptive Replacement Cache）
+// t1 保存只访问过一次的 key，t2 保存访问过多次的 key，
+// b1 和 b2 是从 t1 和 t2 淘汰的 key 的记录（幽灵），只有 key 没有值。
+// 命中幽灵说明对应的列表淘汰得太早了，于是调整 t1 的目标大小 p
+//
+// 因为 MaxMemoryCache 按照内存淘汰，没有固定的容量，所以用当前缓存的 key 的数量作为容量
+type ARCPolicy struct {
+	p       int
+	lists   [4]*list.List
+	entries map[string]*arcEntry
+}
+
+type arcEntry struct {
+	key   string
+	where int
+	ele   *list.Element
+}
+
+func NewARCPolicy() *ARCPolicy {
+	return &ARCPolicy{
+		lists:   [4]*list.List{list.New(), list.New(), list.New(), list.New()},
+		entries: make(map[string]*arcEntry, 1024),
+	}
+}
+
+func (a *ARCPolicy) RecordAccess(key string) {
+	entry, ok := a.entries[key]
+	if !ok || (entry.where != arcT1 && entry.where != arcT2) {
+		return
+	}
+	a.move(entry, arcT2)
+}
+
+func (a *ARCPolicy) RecordInsert(key string) {
+	entry, ok := a.entries[key]
+	if !ok {
+		entry = &arcEntry{key: key, where: arcT1}
+		entry.ele = a.lists[arcT1].PushFront(entry)
+		a.entries[key] = entry
+		a.trimGhosts()
+		return
+	}
+	b1, b2 := a.lists[arcB1].Len(), a.lists[arcB2].Len()
+	switch entry.where {
+	case arcB1:
+		// t1 太小了
+		a.p += maxInt(b2/b1, 1)
+		if c := a.resident() + 1; a.p > c {
+			a.p = c
+		}
+	case arcB2:
+		// t2 太小了
+		a.p -= maxInt(b1/b2, 1)
+		if a.p < 0 {
+			a.p = 0
+		}
+	}
+	a.move(entry, arcT2)
+	a.trimGhosts()
+}
+
+func (a *ARCPolicy) Victim() (string, bool) {
+	t1, t2 := a.lists[arcT1], a.lists[arcT2]
+	var entry *arcEntry
+	switch {
+	case t1.Len() > 0 && (t1.Len() > a.p || t2.Len() == 0):
+		entry = t1.Back().Value.(*arcEntry)
+		a.move(entry, arcB1)
+	case t2.Len() > 0:
+		entry = t2.Back().Value.(*arcEntry)
+		a.move(entry, arcB2)
+	default:
+		return "", false
+	}
+	return entry.key, true
+}
+
+func (a *ARCPolicy) Remove(key string) {
+	if entry, ok := a.entries[key]; ok {
+		a.lists[entry.where].Remove(entry.ele)
+		delete(a.entries, key)
+	}
+}
+
+func (a *ARCPolicy) move(entry *arcEntry, where int) {
+	a.lists[entry.where].Remove(entry.ele)
+	entry.where = where
+	entry.ele = a.lists[where].PushFront(entry)
+}
+
+func (a *ARCPolicy) resident() int {
+	return a.lists[arcT1].Len() + a.lists[arcT2].Len()
+}
+
+// trimGhosts 控制幽灵的数量：t1 + b1 不超过容量，全部加起来不超过两倍容量
+func (a *ARCPolicy) trimGhosts() {
+	c := a.resident()
+	b1, b2 := a.lists[arcB1], a.lists[arcB2]
+	for b1.Len() > 0 && a.lists[arcT1].Len()+b1.Len() > c {
+		a.Remove(b1.Back().Value.(*arcEntry).key)
+	}
+	for b2.Len() > 0 && c+b1.Len()+b2.Len() > 2*c {
+		a.Remove(b2.Back().Value.(*arcEntry).key)
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
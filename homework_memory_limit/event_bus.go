@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SlowConsumerPolicy 订阅者的缓冲满了之后怎么处理新的事件
+type SlowConsumerPolicy int
+
+const (
+	// DropNewest 丢弃新的事件，这是默认的策略
+	DropNewest SlowConsumerPolicy = iota
+	// DropOldest 丢弃缓冲里面最旧的事件，保留新的事件
+	DropOldest
+	// Block 阻塞发布者，直到订阅者读取或者取消订阅
+	// 缓存在持有锁的时候发布事件，所以会拖慢整个缓存，慎用
+	Block
+	// Disconnect 取消订阅，关闭 channel
+	Disconnect
+)
+
+const defaultEventBuffer = 128
+
+type SubscribeOption func(s *subscriber)
+
+// SubscribeWithBuffer 设置缓冲的大小，默认 128
+func SubscribeWithBuffer(size int) SubscribeOption {
+	return func(s *subscriber) {
+		s.buffer = size
+	}
+}
+
+// SubscribeWithPolicy 设置缓冲满了之后的策略，默认 DropNewest
+func SubscribeWithPolicy(policy SlowConsumerPolicy) SubscribeOption {
+	return func(s *subscriber) {
+		s.policy = policy
+	}
+}
+
+// SubscribeWithTypes 只订阅某些类型的事件，默认订阅所有的事件
+func SubscribeWithTypes(types ...int) SubscribeOption {
+	return func(s *subscriber) {
+		s.types = make(map[int]struct{}, len(types))
+		for _, typ := range types {
+			s.types[typ] = struct{}{}
+		}
+	}
+}
+
+// EventBus 把事件发给所有的订阅者，每个订阅者有自己的缓冲
+// 订阅者列表是写时复制的，发布的时候不需要加锁
+type EventBus struct {
+	mutex sync.Mutex
+	// subs 的类型是 []*subscriber，只在持有 mutex 的时候替换
+	subs   atomic.Value
+	closed bool
+}
+
+type subscriber struct {
+	// dropped 放在最前面，保证 64 位对齐
+	dropped uint64
+
+	ch     chan Event
+	buffer int
+	policy SlowConsumerPolicy
+	types  map[int]struct{}
+
+	// 发布的时候持有读锁，关闭 ch 的时候持有写锁，避免往已经关闭的 ch 发送
+	mutex  sync.RWMutex
+	closed bool
+	// done 取消订阅的时候关闭，唤醒阻塞的发布者，让它释放读锁
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func NewEventBus() *EventBus {
+	res := &EventBus{}
+	res.subs.Store([]*subscriber(nil))
+	return res
+}
+
+// Subscribe 订阅事件，EventBus 已经关闭的话返回一个已经关闭的 channel
+func (b *EventBus) Subscribe(opts ...SubscribeOption) <-chan Event {
+	s := &subscriber{
+		buffer: defaultEventBuffer,
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ch = make(chan Event, s.buffer)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		s.close()
+		return s.ch
+	}
+	subs := b.load()
+	newSubs := make([]*subscriber, len(subs), len(subs)+1)
+	copy(newSubs, subs)
+	b.subs.Store(append(newSubs, s))
+	return s.ch
+}
+
+// Unsubscribe 取消订阅并且关闭 ch，重复取消没有影响
+func (b *EventBus) Unsubscribe(ch <-chan Event) {
+	b.mutex.Lock()
+	subs := b.load()
+	var target *subscriber
+	newSubs := make([]*subscriber, 0, len(subs))
+	for _, s := range subs {
+		if s.ch == ch {
+			target = s
+			continue
+		}
+		newSubs = append(newSubs, s)
+	}
+	if target != nil {
+		b.subs.Store(newSubs)
+	}
+	b.mutex.Unlock()
+	if target != nil {
+		target.close()
+	}
+}
+
+// Dropped 订阅者因为缓冲满了丢弃的事件数量
+func (b *EventBus) Dropped(ch <-chan Event) uint64 {
+	for _, s := range b.load() {
+		if s.ch == ch {
+			return atomic.LoadUint64(&s.dropped)
+		}
+	}
+	return 0
+}
+
+// Publish 把事件发给所有订阅了这个类型的订阅者
+func (b *EventBus) Publish(e Event) {
+	for _, s := range b.load() {
+		if !s.publish(e) {
+			b.Unsubscribe(s.ch)
+		}
+	}
+}
+
+// Close 关闭所有订阅者的 channel，之后发布的事件都会被丢弃
+func (b *EventBus) Close() {
+	b.mutex.Lock()
+	subs := b.load()
+	b.subs.Store([]*subscriber(nil))
+	b.closed = true
+	b.mutex.Unlock()
+	for _, s := range subs {
+		s.close()
+	}
+}
+
+func (b *EventBus) load() []*subscriber {
+	return b.subs.Load().([]*subscriber)
+}
+
+func (s *subscriber) close() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// publish 返回 false 说明要断开这个订阅者
+func (s *subscriber) publish(e Event) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return true
+	}
+	if s.types != nil {
+		if _, ok := s.types[e.Type]; !ok {
+			return true
+		}
+	}
+	select {
+	case s.ch <- e:
+		return true
+	default:
+	}
+
+	switch s.policy {
+	case Block:
+		select {
+		case s.ch <- e:
+		case <-s.done:
+		}
+	case DropOldest:
+		if cap(s.ch) == 0 {
+			atomic.AddUint64(&s.dropped, 1)
+			return true
+		}
+		for {
+			select {
+			case s.ch <- e:
+				return true
+			default:
+			}
+			select {
+			case <-s.ch:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	case Disconnect:
+		atomic.AddUint64(&s.dropped, 1)
+		return false
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drain 读取 channel 里面已有的事件
+func drain(ch <-chan Event) []Event {
+	var res []Event
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return res
+			}
+			res = append(res, e)
+		default:
+			return res
+		}
+	}
+}
+
+func TestEventBus_Publish(t *testing.T) {
+	bus := NewEventBus()
+	all := bus.Subscribe()
+	sets := bus.Subscribe(SubscribeWithTypes(EventSet))
+
+	bus.Publish(Event{Key: "key1", Type: EventSet})
+	bus.Publish(Event{Key: "key1", Type: EventDelete})
+
+	assert.Equal(t, []Event{
+		{Key: "key1", Type: EventSet},
+		{Key: "key1", Type: EventDelete},
+	}, drain(all))
+	assert.Equal(t, []Event{{Key: "key1", Type: EventSet}}, drain(sets))
+
+	bus.Unsubscribe(sets)
+	bus.Unsubscribe(sets)
+	_, ok := <-sets
+	assert.False(t, ok)
+	bus.Publish(Event{Key: "key2", Type: EventSet})
+	assert.Equal(t, []Event{{Key: "key2", Type: EventSet}}, drain(all))
+}
+
+func TestEventBus_SlowConsumer(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policy      SlowConsumerPolicy
+		wantEvents  []Event
+		wantDropped uint64
+	}{
+		{
+			name:        "drop newest",
+			policy:      DropNewest,
+			wantEvents:  []Event{{Key: "1"}, {Key: "2"}},
+			wantDropped: 2,
+		},
+		{
+			name:        "drop oldest",
+			policy:      DropOldest,
+			wantEvents:  []Event{{Key: "3"}, {Key: "4"}},
+			wantDropped: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bus := NewEventBus()
+			ch := bus.Subscribe(SubscribeWithBuffer(2), SubscribeWithPolicy(tc.policy))
+			for _, key := range []string{"1", "2", "3", "4"} {
+				bus.Publish(Event{Key: key})
+			}
+			assert.Equal(t, tc.wantDropped, bus.Dropped(ch))
+			assert.Equal(t, tc.wantEvents, drain(ch))
+		})
+	}
+}
+
+func TestEventBus_Disconnect(t *testing.T) {
+	bus := NewEventBus()
+	slow := bus.Subscribe(SubscribeWithBuffer(1), SubscribeWithPolicy(Disconnect))
+	other := bus.Subscribe()
+	bus.Publish(Event{Key: "1"})
+	bus.Publish(Event{Key: "2"})
+
+	// 缓冲里面已有的事件还可以读到，之后 channel 被关闭
+	assert.Equal(t, []Event{{Key: "1"}}, drain(slow))
+	_, ok := <-slow
+	assert.False(t, ok)
+	assert.Equal(t, []Event{{Key: "1"}, {Key: "2"}}, drain(other))
+}
+
+func TestEventBus_Block(t *testing.T) {
+	bus := NewEventBus()
+	ch := bus.Subscribe(SubscribeWithBuffer(0), SubscribeWithPolicy(Block))
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(Event{Key: "1"})
+		bus.Publish(Event{Key: "2"})
+		close(published)
+	}()
+	assert.Equal(t, Event{Key: "1"}, <-ch)
+
+	// 取消订阅会唤醒阻塞的发布者，同时订阅也不会被阻塞
+	_ = bus.Subscribe()
+	bus.Unsubscribe(ch)
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("发布者没有被唤醒")
+	}
+}
+
+func TestEventBus_Close(t *testing.T) {
+	bus := NewEventBus()
+	ch := bus.Subscribe()
+	bus.Publish(Event{Key: "1"})
+	bus.Close()
+	assert.Equal(t, []Event{{Key: "1"}}, drain(ch))
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	// 关闭之后订阅拿到的是已经关闭的 channel
+	_, ok = <-bus.Subscribe()
+	assert.False(t, ok)
+	bus.Publish(Event{Key: "2"})
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ CacheV1 = &EventCache{}
+
+type EventCacheOption func(c *EventCache)
+
+// EventCacheWithSubscribeOptions 设置 Subscribe 使用的订阅选项
+func EventCacheWithSubscribeOptions(opts ...SubscribeOption) EventCacheOption {
+	return func(c *EventCache) {
+		c.subOpts = opts
+	}
+}
+
+// EventCache 装饰 Cache，把增删改查、过期和淘汰作为事件发布给订阅者
+// 被装饰的 Cache 删除 key 的时候，没有到过期时间的算作淘汰，
+// 例如装饰 MaxMemoryCache 的时候，超过最大内存被淘汰的 key
+type EventCache struct {
+	Cache
+	bus     *EventBus
+	subOpts []SubscribeOption
+
+	// 被装饰的 Cache 回调的时候持有自己的锁，
+	// 所以持有 mutex 的时候不能调用被装饰的 Cache
+	mutex sync.Mutex
+	// deadlines 设置了过期时间的 key，用来区分过期和淘汰
+	deadlines map[string]time.Time
+	// deleting 正在通过 Delete 和 LoadAndDelete 删除的 key
+	deleting map[string]*pendingDelete
+}
+
+type pendingDelete struct {
+	cnt int
+	// found 回调的时候 key 还存在
+	found bool
+	val   []byte
+}
+
+func NewEventCache(cache Cache, opts ...EventCacheOption) *EventCache {
+	res := &EventCache{
+		Cache:     cache,
+		bus:       NewEventBus(),
+		deadlines: make(map[string]time.Time, 1024),
+		deleting:  make(map[string]*pendingDelete, 8),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	cache.OnEvicted(res.onEvicted)
+	return res
+}
+
+func (e *EventCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	if err := e.Cache.Set(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	if expiration > 0 {
+		e.deadlines[key] = time.Now().Add(expiration)
+	} else {
+		delete(e.deadlines, key)
+	}
+	e.mutex.Unlock()
+	e.bus.Publish(Event{Key: key, Val: val, Type: EventSet})
+	return nil
+}
+
+// Get 只有命中的时候发布事件
+func (e *EventCache) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := e.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	e.bus.Publish(Event{Key: key, Val: val, Type: EventGet})
+	return val, nil
+}
+
+// Delete key 存在的时候发布事件
+func (e *EventCache) Delete(ctx context.Context, key string) error {
+	e.startDelete(key)
+	err := e.Cache.Delete(ctx, key)
+	found, val := e.endDelete(key)
+	if err != nil {
+		return err
+	}
+	if found {
+		e.bus.Publish(Event{Key: key, Val: val, Type: EventDelete})
+	}
+	return nil
+}
+
+func (e *EventCache) LoadAndDelete(ctx context.Context, key string) ([]byte, error) {
+	e.startDelete(key)
+	val, err := e.Cache.LoadAndDelete(ctx, key)
+	e.endDelete(key)
+	if err != nil {
+		return nil, err
+	}
+	e.bus.Publish(Event{Key: key, Val: val, Type: EventDelete})
+	return val, nil
+}
+
+// Subscribe 使用 EventCacheWithSubscribeOptions 设置的选项订阅
+func (e *EventCache) Subscribe() <-chan Event {
+	return e.bus.Subscribe(e.subOpts...)
+}
+
+// SubscribeWith 使用指定的选项订阅
+func (e *EventCache) SubscribeWith(opts ...SubscribeOption) <-chan Event {
+	return e.bus.Subscribe(opts...)
+}
+
+func (e *EventCache) Unsubscribe(ch <-chan Event) {
+	e.bus.Unsubscribe(ch)
+}
+
+// Dropped 订阅者因为缓冲满了丢弃的事件数量
+func (e *EventCache) Dropped(ch <-chan Event) uint64 {
+	return e.bus.Dropped(ch)
+}
+
+// Close 关闭所有订阅者，不会关闭被装饰的 Cache
+func (e *EventCache) Close() error {
+	e.bus.Close()
+	return nil
+}
+
+func (e *EventCache) startDelete(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	pd, ok := e.deleting[key]
+	if !ok {
+		pd = &pendingDelete{}
+		e.deleting[key] = pd
+	}
+	pd.cnt++
+}
+
+func (e *EventCache) endDelete(key string) (bool, []byte) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	pd := e.deleting[key]
+	found, val := pd.found, pd.val
+	pd.found, pd.val = false, nil
+	pd.cnt--
+	if pd.cnt == 0 {
+		delete(e.deleting, key)
+	}
+	return found, val
+}
+
+func (e *EventCache) onEvicted(key string, val []byte) {
+	e.mutex.Lock()
+	dl, hasDeadline := e.deadlines[key]
+	delete(e.deadlines, key)
+	if pd, ok := e.deleting[key]; ok {
+		// Delete 和 LoadAndDelete 自己发布事件
+		pd.found, pd.val = true, val
+		e.mutex.Unlock()
+		return
+	}
+	e.mutex.Unlock()
+
+	typ := EventEvict
+	if hasDeadline && !time.Now().Before(dl) {
+		typ = EventExpire
+	}
+	e.bus.Publish(Event{Key: key, Val: val, Type: typ})
+}
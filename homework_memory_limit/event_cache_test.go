@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventCache(t *testing.T) {
+	c := NewMemoryMapCache(time.Minute)
+	defer c.Close()
+	ec := NewEventCache(c)
+	defer ec.Close()
+	ch := ec.Subscribe()
+	ctx := context.Background()
+
+	require.NoError(t, ec.Set(ctx, "key1", []byte("value1"), time.Minute))
+	_, err := ec.Get(ctx, "key1")
+	require.NoError(t, err)
+	// 没有命中不发布
+	_, err = ec.Get(ctx, "key2")
+	assert.ErrorIs(t, err, errKeyNotFound)
+	require.NoError(t, ec.Delete(ctx, "key1"))
+	// key 不存在不发布
+	require.NoError(t, ec.Delete(ctx, "key1"))
+	require.NoError(t, ec.Set(ctx, "key3", []byte("value3"), 0))
+	val, err := ec.LoadAndDelete(ctx, "key3")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value3"), val)
+
+	assert.Equal(t, []Event{
+		{Key: "key1", Val: []byte("value1"), Type: EventSet},
+		{Key: "key1", Val: []byte("value1"), Type: EventGet},
+		{Key: "key1", Val: []byte("value1"), Type: EventDelete},
+		{Key: "key3", Val: []byte("value3"), Type: EventSet},
+		{Key: "key3", Val: []byte("value3"), Type: EventDelete},
+	}, drain(ch))
+}
+
+func TestEventCache_ExpireAndEvict(t *testing.T) {
+	c := NewMemoryMapCache(time.Minute)
+	defer c.Close()
+	var mutex sync.Mutex
+	var evicted []string
+	maxMemCache := NewMaxMemoryCache(20, c)
+	// OnEvicted 是追加回调，不会覆盖 MaxMemoryCache 自己的回调
+	maxMemCache.OnEvicted(func(key string, val []byte) {
+		mutex.Lock()
+		evicted = append(evicted, key)
+		mutex.Unlock()
+	})
+	ec := NewEventCache(maxMemCache,
+		EventCacheWithSubscribeOptions(SubscribeWithTypes(EventExpire, EventEvict)))
+	defer ec.Close()
+	ch := ec.Subscribe()
+	ctx := context.Background()
+
+	require.NoError(t, ec.Set(ctx, "key1", []byte("0123456789"), time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	_, err := ec.Get(ctx, "key1")
+	assert.ErrorIs(t, err, errKeyNotFound)
+
+	require.NoError(t, ec.Set(ctx, "key2", []byte("0123456789"), time.Minute))
+	require.NoError(t, ec.Set(ctx, "key3", []byte("0123456789"), time.Minute))
+	require.NoError(t, ec.Set(ctx, "key4", []byte("0123456789"), time.Minute))
+
+	assert.Equal(t, []Event{
+		{Key: "key1", Val: []byte("0123456789"), Type: EventExpire},
+		{Key: "key2", Val: []byte("0123456789"), Type: EventEvict},
+	}, drain(ch))
+	assert.Equal(t, int64(20), maxMemCache.Used())
+	mutex.Lock()
+	assert.Equal(t, []string{"key1", "key2"}, evicted)
+	mutex.Unlock()
+}
+
+func TestEventCache_Unsubscribe(t *testing.T) {
+	c := NewMemoryMapCache(time.Minute)
+	defer c.Close()
+	ec := NewEventCache(c)
+	ch1 := ec.Subscribe()
+	ch2 := ec.SubscribeWith(SubscribeWithBuffer(1))
+	ctx := context.Background()
+
+	require.NoError(t, ec.Set(ctx, "key1", []byte("value1"), time.Minute))
+	require.NoError(t, ec.Set(ctx, "key2", []byte("value2"), time.Minute))
+	assert.Equal(t, uint64(1), ec.Dropped(ch2))
+
+	ec.Unsubscribe(ch1)
+	require.NoError(t, ec.Set(ctx, "key3", []byte("value3"), time.Minute))
+	assert.Len(t, drain(ch1), 2)
+	_, ok := <-ch1
+	assert.False(t, ok)
+
+	require.NoError(t, ec.Close())
+	assert.Len(t, drain(ch2), 1)
+}
@@ -0,0 +1,116 @@
+package cache
+
+import "container/list"
+
+// EvictionPolicy 淘汰策略，决定内存不够的时候淘汰哪一个 key
+// 实现不需要是并发安全的，MaxMemoryCache 会在持有锁的时候调用
+type EvictionPolicy interface {
+	// RecordAccess 记录一次对已有 key 的访问
+	RecordAccess(key string)
+	// RecordInsert 记录插入一个新的 key，key 已经存在的话当作访问
+	RecordInsert(key string)
+	// Victim 选出下一个要淘汰的 key，并且把它从策略里面删除
+	// 没有 key 的时候返回 false
+	Victim() (string, bool)
+	// Remove 删除 key，例如 key 过期或者被主动删除
+	Remove(key string)
+}
+
+var (
+	_ EvictionPolicy = &LRUPolicy{}
+	_ EvictionPolicy = &FIFOPolicy{}
+)
+
+// LRUPolicy 淘汰最久没有被访问的 key
+type LRUPolicy struct {
+	// 队首是最近访问的
+	list    *list.List
+	entries map[string]*list.Element
+}
+
+func NewLRUPolicy() *LRUPolicy {
+	return &LRUPolicy{
+		list:    list.New(),
+		entries: make(map[string]*list.Element, 1024),
+	}
+}
+
+func (l *LRUPolicy) RecordAccess(key string) {
+	if ele, ok := l.entries[key]; ok {
+		l.list.MoveToFront(ele)
+	}
+}
+
+func (l *LRUPolicy) RecordInsert(key string) {
+	if ele, ok := l.entries[key]; ok {
+		l.list.MoveToFront(ele)
+		return
+	}
+	l.entries[key] = l.list.PushFront(key)
+}
+
+func (l *LRUPolicy) Victim() (string, bool) {
+	ele := l.list.Back()
+	if ele == nil {
+		return "", false
+	}
+	key := l.list.Remove(ele).(string)
+	delete(l.entries, key)
+	return key, true
+}
+
+func (l *LRUPolicy) Remove(key string) {
+	if ele, ok := l.entries[key]; ok {
+		l.list.Remove(ele)
+		delete(l.entries, key)
+	}
+}
+
+// keys 按照从最近访问到最久没有访问的顺序返回所有的 key
+func (l *LRUPolicy) keys() []string {
+	res := make([]string, 0, l.list.Len())
+	for ele := l.list.Front(); ele != nil; ele = ele.Next() {
+		res = append(res, ele.Value.(string))
+	}
+	return res
+}
+
+// FIFOPolicy 淘汰最早插入的 key，访问和覆写都不会改变顺序
+type FIFOPolicy struct {
+	// 队首是最新插入的
+	list    *list.List
+	entries map[string]*list.Element
+}
+
+func NewFIFOPolicy() *FIFOPolicy {
+	return &FIFOPolicy{
+		list:    list.New(),
+		entries: make(map[string]*list.Element, 1024),
+	}
+}
+
+func (f *FIFOPolicy) RecordAccess(key string) {}
+
+func (f *FIFOPolicy) RecordInsert(key string) {
+	if _, ok := f.entries[key]; ok {
+		return
+	}
+	f.entries[key] = f.list.PushFront(key)
+}
+
+func (f *FIFOPolicy) Victim() (string, bool) {
+	ele := f.list.Back()
+	if ele == nil {
+		return "", false
+	}
+	key := f.list.Remove(ele).(string)
+	delete(f.entries, key)
+	return key, true
+}
+
+func (f *FIFOPolicy) Remove(key string) {
+	if ele, ok := f.entries[key]; ok {
+		f.list.Remove(ele)
+		delete(f.entries, key)
+	}
+}
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// victims 依次淘汰所有的 key
+func victims(p EvictionPolicy) []string {
+	var res []string
+	for {
+		key, ok := p.Victim()
+		if !ok {
+			return res
+		}
+		res = append(res, key)
+	}
+}
+
+func TestEvictionPolicy(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy EvictionPolicy
+		// 插入 a、b、c、d 之后执行
+		before      func(p EvictionPolicy)
+		wantVictims []string
+	}{
+		{
+			name:        "lru",
+			policy:      NewLRUPolicy(),
+			wantVictims: []string{"b", "c", "d", "a"},
+			before: func(p EvictionPolicy) {
+				p.RecordAccess("a")
+			},
+		},
+		{
+			name:        "lru remove",
+			policy:      NewLRUPolicy(),
+			wantVictims: []string{"a", "c", "d"},
+			before: func(p EvictionPolicy) {
+				p.Remove("b")
+				p.Remove("not exist")
+			},
+		},
+		{
+			name:        "fifo",
+			policy:      NewFIFOPolicy(),
+			wantVictims: []string{"a", "b", "c", "d"},
+			before: func(p EvictionPolicy) {
+				p.RecordAccess("a")
+				p.RecordInsert("a")
+			},
+		},
+		{
+			name:        "lfu",
+			policy:      NewLFUPolicy(),
+			wantVictims: []string{"c", "d", "b", "a"},
+			before: func(p EvictionPolicy) {
+				p.RecordAccess("a")
+				p.RecordAccess("a")
+				p.RecordAccess("b")
+				p.Remove("not exist")
+			},
+		},
+		{
+			name:   "lfu same frequency",
+			policy: NewLFUPolicy(),
+			// 次数一样的时候淘汰最久没有访问的
+			wantVictims: []string{"a", "d", "c", "b"},
+			before: func(p EvictionPolicy) {
+				p.RecordAccess("c")
+				p.RecordAccess("b")
+				p.RecordAccess("c")
+				p.RecordAccess("b")
+			},
+		},
+		{
+			name:   "arc",
+			policy: NewARCPolicy(),
+			// 访问过两次的进入 t2，t1 先被淘汰
+			wantVictims: []string{"b", "d", "c", "a"},
+			before: func(p EvictionPolicy) {
+				p.RecordAccess("c")
+				p.RecordAccess("a")
+			},
+		},
+		{
+			name:        "tinylfu",
+			policy:      NewTinyLFUPolicy(100),
+			wantVictims: []string{"b", "c", "a", "d"},
+			before: func(p EvictionPolicy) {
+				// a 进入保护区，d 在窗口里面
+				p.RecordAccess("a")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{"a", "b", "c", "d"} {
+				tc.policy.RecordInsert(key)
+			}
+			tc.before(tc.policy)
+			assert.Equal(t, tc.wantVictims, victims(tc.policy))
+			_, ok := tc.policy.Victim()
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestARCPolicy_Ghost(t *testing.T) {
+	p := NewARCPolicy()
+	for _, key := range []string{"a", "b", "c"} {
+		p.RecordInsert(key)
+	}
+	key, ok := p.Victim()
+	require.True(t, ok)
+	assert.Equal(t, "a", key)
+	assert.Equal(t, 0, p.p)
+
+	// a 在 b1 里面，重新插入说明 t1 太小了
+	p.RecordInsert("a")
+	assert.Equal(t, 1, p.p)
+	assert.Equal(t, arcT2, p.entries["a"].where)
+	p.RecordInsert("d")
+
+	// t1 的大小超过 p 的时候从 t1 淘汰，否则从 t2 淘汰
+	assert.Equal(t, []string{"b", "c", "a", "d"}, victims(p))
+}
+
+func TestTinyLFUPolicy_Admission(t *testing.T) {
+	// 窗口只有一个 key
+	p := NewTinyLFUPolicy(10)
+	for i := 0; i < 9; i++ {
+		key := strconv.Itoa(i)
+		p.RecordInsert(key)
+		// 主缓存里面的 key 都很热
+		for j := 0; j < 3; j++ {
+			p.RecordAccess(key)
+		}
+	}
+	p.RecordInsert("cold")
+	p.RecordInsert("new")
+	// 窗口超出了容量，候选者 cold 的频率比主缓存的低，被拒绝
+	key, ok := p.Victim()
+	require.True(t, ok)
+	assert.Equal(t, "cold", key)
+
+	// 候选者频率高，被接纳，淘汰主缓存最旧的 key
+	for j := 0; j < 10; j++ {
+		p.RecordAccess("new")
+	}
+	p.RecordInsert("newer")
+	key, ok = p.Victim()
+	require.True(t, ok)
+	assert.NotEqual(t, "new", key)
+	assert.Equal(t, tinyLFUProbation, p.entries["new"].where)
+}
+
+func TestMaxMemoryCache_EvictionPolicy(t *testing.T) {
+	cache := NewMemoryMapCache(time.Minute)
+	defer cache.Close()
+	maxMemCache := NewMaxMemoryCache(30, cache, MaxMemoryCacheWithEvictionPolicy(NewLFUPolicy()))
+	ctx := context.Background()
+	val := []byte("0123456789")
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, maxMemCache.Set(ctx, key, val, time.Minute))
+	}
+	_, err := maxMemCache.Get(ctx, "a")
+	require.NoError(t, err)
+	_, err = maxMemCache.Get(ctx, "c")
+	require.NoError(t, err)
+
+	// b 访问的次数最少
+	require.NoError(t, maxMemCache.Set(ctx, "d", val, time.Minute))
+	_, err = maxMemCache.Get(ctx, "b")
+	assert.ErrorIs(t, err, errKeyNotFound)
+
+	// 覆写成更大的值，d 自己访问次数最少，不能把自己淘汰掉
+	require.NoError(t, maxMemCache.Set(ctx, "d", []byte("0123456789abcde"), time.Minute))
+	_, err = maxMemCache.Get(ctx, "d")
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), maxMemCache.Used())
+
+	assert.Equal(t, Stats{Hits: 3, Misses: 1, Evictions: 2}, maxMemCache.Stats())
+	assert.Equal(t, 0.75, maxMemCache.Stats().HitRate())
+}
+
+// TestEvictionPolicy_Replay 用 Zipf 分布的请求回放，比较不同策略的命中率
+// 用真实流量比较的时候，把 trace 换成线上的请求记录就可以
+func TestEvictionPolicy_Replay(t *testing.T) {
+	const (
+		keys     = 10000
+		requests = 200000
+		capacity = 500
+	)
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, keys-1)
+	trace := make([]string, requests)
+	for i := range trace {
+		trace[i] = strconv.FormatUint(zipf.Uint64(), 10)
+	}
+
+	policies := []struct {
+		name   string
+		policy EvictionPolicy
+	}{
+		{name: "fifo", policy: NewFIFOPolicy()},
+		{name: "lru", policy: NewLRUPolicy()},
+		{name: "lfu", policy: NewLFUPolicy()},
+		{name: "arc", policy: NewARCPolicy()},
+		{name: "tinylfu", policy: NewTinyLFUPolicy(capacity)},
+	}
+	rates := make(map[string]float64, len(policies))
+	for _, p := range policies {
+		stats := replay(t, p.policy, trace, capacity)
+		rates[p.name] = stats.HitRate()
+		t.Logf("%-8s hit rate: %.4f, evictions: %d", p.name, stats.HitRate(), stats.Evictions)
+	}
+	assert.Greater(t, rates["lru"], rates["fifo"])
+	assert.Greater(t, rates["tinylfu"], rates["lru"])
+	assert.Greater(t, rates["arc"], rates["lru"])
+}
+
+// replay 每个值占 1 个字节，所以最多缓存 capacity 个 key
+func replay(t *testing.T, policy EvictionPolicy, trace []string, capacity int64) Stats {
+	cache := NewMemoryMapCache(time.Minute)
+	defer cache.Close()
+	maxMemCache := NewMaxMemoryCache(capacity, cache, MaxMemoryCacheWithEvictionPolicy(policy))
+	ctx := context.Background()
+	for _, key := range trace {
+		if _, err := maxMemCache.Get(ctx, key); err != nil {
+			require.NoError(t, maxMemCache.Set(ctx, key, []byte{1}, time.Hour))
+		}
+	}
+	return maxMemCache.Stats()
+}
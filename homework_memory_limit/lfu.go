@@ -0,0 +1,89 @@
+package cache
+
+import "container/list"
+
+var _ EvictionPolicy = &LFUPolicy{}
+
+// LFUPolicy 淘汰访问次数最少的 key，次数一样的时候淘汰最久没有访问的
+// 按照访问次数把 key 分组，组按照次数从小到大串起来，所以所有操作都是 O(1) 的
+type LFUPolicy struct {
+	// freqs 的元素是 *lfuFreq，从队首到队尾访问次数递增
+	freqs   *list.List
+	entries map[string]*lfuEntry
+}
+
+type lfuFreq struct {
+	freq int
+	// 队首是最近访问的
+	keys *list.List
+}
+
+type lfuEntry struct {
+	key  string
+	freq *list.Element
+	ele  *list.Element
+}
+
+func NewLFUPolicy() *LFUPolicy {
+	return &LFUPolicy{
+		freqs:   list.New(),
+		entries: make(map[string]*lfuEntry, 1024),
+	}
+}
+
+func (l *LFUPolicy) RecordAccess(key string) {
+	entry, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	cur := entry.freq
+	freq := cur.Value.(*lfuFreq).freq + 1
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuFreq).freq != freq {
+		next = l.freqs.InsertAfter(&lfuFreq{freq: freq, keys: list.New()}, cur)
+	}
+	l.removeFromFreq(entry)
+	entry.freq = next
+	entry.ele = next.Value.(*lfuFreq).keys.PushFront(entry)
+}
+
+func (l *LFUPolicy) RecordInsert(key string) {
+	if _, ok := l.entries[key]; ok {
+		l.RecordAccess(key)
+		return
+	}
+	front := l.freqs.Front()
+	if front == nil || front.Value.(*lfuFreq).freq != 1 {
+		front = l.freqs.PushFront(&lfuFreq{freq: 1, keys: list.New()})
+	}
+	entry := &lfuEntry{key: key, freq: front}
+	entry.ele = front.Value.(*lfuFreq).keys.PushFront(entry)
+	l.entries[key] = entry
+}
+
+func (l *LFUPolicy) Victim() (string, bool) {
+	front := l.freqs.Front()
+	if front == nil {
+		return "", false
+	}
+	entry := front.Value.(*lfuFreq).keys.Back().Value.(*lfuEntry)
+	l.removeFromFreq(entry)
+	delete(l.entries, entry.key)
+	return entry.key, true
+}
+
+func (l *LFUPolicy) Remove(key string) {
+	if entry, ok := l.entries[key]; ok {
+		l.removeFromFreq(entry)
+		delete(l.entries, key)
+	}
+}
+
+// removeFromFreq 把 key 从所在的组里面删除，组空了的话一起删除
+func (l *LFUPolicy) removeFromFreq(entry *lfuEntry) {
+	keys := entry.freq.Value.(*lfuFreq).keys
+	keys.Remove(entry.ele)
+	if keys.Len() == 0 {
+		l.freqs.Remove(entry.freq)
+	}
+}
@@ -2,144 +2,217 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/gotomicro/ekit/list"
 )
 
+var errOverMaxMemory = errors.New("cache: 超过最大内存")
+
+type MaxMemoryCacheOption func(cache *MaxMemoryCache)
+
+// MaxMemoryCache 限制缓存的值占用的内存，超过之后按照淘汰策略淘汰，默认是 LRU
+//
+// 被装饰的 Cache 在淘汰的时候会回调 OnEvicted，回调的时候它持有自己的锁，
+// 所以 MaxMemoryCache 不能在持有 mutex 的时候调用它，否则会死锁。
+// 同一个 key 的 Set 和淘汰用 keyMutexes 串行，保证记账的顺序和写入被装饰的 Cache 的顺序一致
 type MaxMemoryCache struct {
+	// 统计数据放在最前面，保证 64 位对齐
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
 	Cache
 	max  int64
 	used int64
-	lock sync.Mutex
-	list *list.LinkedList[string] // 队尾元素为 Get和Set时的 设置元素，  对首为最少使用元素，需要淘汰
+
+	keyMutexes [keyMutexes]sync.Mutex
+
+	mutex   sync.Mutex
+	policy  EvictionPolicy
+	entries map[string]sizeEntry
 }
 
-func NewMaxMemoryCache(max int64, cache Cache) *MaxMemoryCache {
-	res := &MaxMemoryCache{
-		max:   max,
-		used:  0,
-		Cache: cache,
-		list:  list.NewLinkedList[string](),
+// keyMutexes 按照 key 的哈希值分段加锁，不同的 key 大概率不会互相阻塞
+const keyMutexes = 256
+
+// sizeEntry 记录 key 的大小和对应的值，
+// 回调的时候用值判断被删除的是不是记录的这一个，而不是已经被覆写的旧值
+type sizeEntry struct {
+	size int64
+	val  []byte
+}
+
+func (e sizeEntry) is(val []byte) bool {
+	if len(e.val) != len(val) {
+		return false
 	}
-	res.Cache.OnEvicted(func(key string, val []byte) {
-		// 注册回调
-		res.lock.Lock()
-		defer res.lock.Unlock()
+	return len(val) == 0 || &e.val[0] == &val[0]
+}
+
+// Stats 缓存的命中统计
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
 
-		res.used -= int64(len(val))
+// HitRate 命中率，没有请求的时候是 0
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
 
-		for i := 0; i < res.list.Len(); i++ {
-			item, _ := res.list.Get(i)
-			if key == item {
-				res.list.Delete(i)
-			}
+// MaxMemoryCacheWithEvictionPolicy 设置淘汰策略
+func MaxMemoryCacheWithEvictionPolicy(policy EvictionPolicy) MaxMemoryCacheOption {
+	return func(cache *MaxMemoryCache) {
+		cache.policy = policy
+	}
+}
+
+func NewMaxMemoryCache(max int64, cache Cache, opts ...MaxMemoryCacheOption) *MaxMemoryCache {
+	res := &MaxMemoryCache{
+		max:     max,
+		Cache:   cache,
+		entries: make(map[string]sizeEntry, 1024),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	if res.policy == nil {
+		res.policy = NewLRUPolicy()
+	}
+	res.Cache.OnEvicted(func(key string, val []byte) {
+		// 过期、Delete 之类的被装饰的 Cache 自己删除的 key
+		// MaxMemoryCache 自己淘汰的 key 已经从 entries 里面删掉了。
+		// Set 先记账再写入，中间被删掉的是旧值，旧值的大小已经扣除了
+		res.mutex.Lock()
+		defer res.mutex.Unlock()
+		if entry, ok := res.entries[key]; ok && entry.is(val) {
+			delete(res.entries, key)
+			res.used -= entry.size
+			res.policy.Remove(key)
 		}
 	})
-
 	return res
 }
 
 func (m *MaxMemoryCache) Set(ctx context.Context, key string, val []byte,
 	expiration time.Duration) error {
-	// 在这里判断内存使用量，以及腾出空间
-
-	needSize := int64(len(val))
-
-	m.lock.Lock()
-	// 这里不能用defer， 否则 Delete在OnEvicted会拿不到锁
-
-	used := m.used
-	// 如果覆写，则要删除原来的size
-	item, _ := m.Cache.Get(ctx, key)
-	isExists := false
-	if len(item) > 0 {
-		used -= int64(len(item))
-		isExists = true
+	size := int64(len(val))
+	if size > m.max {
+		return fmt.Errorf("%w, key: %s, size: %d", errOverMaxMemory, key, size)
 	}
+	victims, err := m.set(ctx, key, val, size, expiration)
+	atomic.AddUint64(&m.evictions, uint64(len(victims)))
+	// 释放了 key 的锁之后才删除，不然两个 Set 互相淘汰对方的 key 的时候会死锁
+	for _, victim := range victims {
+		m.evict(ctx, victim)
+	}
+	return err
+}
 
-	if m.max < needSize+used {
-		// 清理
-		deleteKey := make([]string, 0, 2)
-		cap := m.list.Len() - 1
-		var free int64 = 0
-		// 获得需要删除key
-		for i := cap; i >= 0; i-- { // 从队尾开始删
-			key, err := m.list.Get(i)
-			if err != nil {
-				m.lock.Unlock()
-				return err
-			}
-
-			item, err := m.Cache.Get(ctx, key)
-			if err != nil {
-				m.lock.Unlock()
-				return err
-			}
-
-			free += int64(len(item))
-			deleteKey = append(deleteKey, key)
-
-			if used-free > needSize {
-				break
-			}
+// set 记账并且写入被装饰的 Cache，返回被淘汰的 key
+func (m *MaxMemoryCache) set(ctx context.Context, key string, val []byte,
+	size int64, expiration time.Duration) ([]string, error) {
+	// 一直持有到写入被装饰的 Cache，不然后记账的可能先写入，记录的大小就不对了
+	keyMutex := m.keyMutex(key)
+	keyMutex.Lock()
+	defer keyMutex.Unlock()
+
+	m.mutex.Lock()
+	old, exist := m.entries[key]
+	if exist {
+		// 覆写，先扣除原来的大小
+		m.used -= old.size
+		m.policy.RecordAccess(key)
+	}
+	// 先淘汰再插入，不然 LFU 之类的策略会把刚插入的 key 淘汰掉
+	var victims []string
+	reinsert := false
+	for m.used+size > m.max {
+		victim, ok := m.policy.Victim()
+		if !ok {
+			break
 		}
-
-		m.lock.Unlock()
-
-		for k := range deleteKey {
-			m.Cache.Delete(ctx, deleteKey[k]) // 这里不需要去减used， 通过OnEvicated去做扣除即可
+		if victim == key {
+			// 覆写的 key 被选中了，它的大小已经扣除，插入之后重新记录
+			reinsert = true
+			continue
 		}
-
-	} else {
-		// 不需要清理，直接加size
-		m.used = m.used - int64(len(item)) + needSize // 先扣除原来的长度，再加新的，即使原来为0 也不影响结果
-		m.lock.Unlock()
+		m.used -= m.entries[victim].size
+		delete(m.entries, victim)
+		victims = append(victims, victim)
+	}
+	m.entries[key] = sizeEntry{size: size, val: val}
+	m.used += size
+	if !exist || reinsert {
+		m.policy.RecordInsert(key)
 	}
+	m.mutex.Unlock()
+	return victims, m.Cache.Set(ctx, key, val, expiration)
+}
 
-	if !isExists { // 不存在就直接添加，减少遍历
-		err := m.list.Add(0, key) // 队首为热数据
-		if err != nil {
-			return err
-		}
-	} else {
-		err := listMoveToHead(m.list, key)
-		if err != nil {
-			return err
-		}
+// evict 从被装饰的 Cache 里面删除淘汰的 key
+// 持有 victim 的锁，并且重新检查，中间又被 Set 的话，新的值不能删
+func (m *MaxMemoryCache) evict(ctx context.Context, victim string) {
+	keyMutex := m.keyMutex(victim)
+	keyMutex.Lock()
+	defer keyMutex.Unlock()
+	m.mutex.Lock()
+	_, ok := m.entries[victim]
+	m.mutex.Unlock()
+	if !ok {
+		// 回调的时候 victim 已经不在 entries 里面了，不会重复扣除
+		_ = m.Cache.Delete(ctx, victim)
 	}
+}
 
-	return m.Cache.Set(ctx, key, val, expiration)
+func (m *MaxMemoryCache) keyMutex(key string) *sync.Mutex {
+	return &m.keyMutexes[hashKey(key)%keyMutexes]
 }
 
+// Get 返回错误的都算作未命中
 func (m *MaxMemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
-
+	// 过期的 key 会在这里被删掉，触发回调，所以不能持有 mutex
 	v, err := m.Cache.Get(ctx, key)
 	if err != nil {
+		atomic.AddUint64(&m.misses, 1)
 		return nil, err
 	}
-
-	//m.list.Add(0, key) // 对尾为热数据
-	err = listMoveToHead(m.list, key)
-	if err != nil {
-		return nil, err
+	atomic.AddUint64(&m.hits, 1)
+	m.mutex.Lock()
+	if _, ok := m.entries[key]; ok {
+		m.policy.RecordAccess(key)
 	}
-
+	m.mutex.Unlock()
 	return v, nil
 }
 
-func listMoveToHead(l *list.LinkedList[string], key string) error {
-	// 这里假设key是存在的，直接要移动
-	for i := 0; i < l.Len(); i++ {
-		item, _ := l.Get(i)
-		if key == item {
-			_, err := l.Delete(i)
-			if err != nil {
-				return err
-			}
-			break
-		}
+// Used 已经使用的内存
+func (m *MaxMemoryCache) Used() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.used
+}
+
+// Stats 从创建开始累计的命中统计
+func (m *MaxMemoryCache) Stats() Stats {
+	return Stats{
+		Hits:      atomic.LoadUint64(&m.hits),
+		Misses:    atomic.LoadUint64(&m.misses),
+		Evictions: atomic.LoadUint64(&m.evictions),
 	}
-	return l.Add(0, key)
+}
+
+// keys 按照从最近使用到最久没有使用的顺序返回所有的 key，只支持 LRUPolicy
+func (m *MaxMemoryCache) keys() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.policy.(*LRUPolicy).keys()
 }
@@ -3,10 +3,12 @@ package cache
 import (
 	"context"
 	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/gotomicro/ekit/list"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -14,15 +16,15 @@ import (
 func TestMaxMemoryCache_Set(t *testing.T) {
 
 	testCases := []struct {
-		name           string
-		before         func(t *testing.T, c Cache)
-		after          func(t *testing.T, c Cache)
-		expiration     time.Duration
-		key            string
-		val            []byte
-		max            int64
-		wantLinkedList *list.LinkedList[string]
-		wantErr        error
+		name       string
+		before     func(t *testing.T, c Cache)
+		after      func(t *testing.T, c Cache)
+		expiration time.Duration
+		key        string
+		val        []byte
+		max        int64
+		wantKeys   []string
+		wantErr    error
 	}{
 		{
 			name: "set success and no need evicted",
@@ -33,12 +35,12 @@ func TestMaxMemoryCache_Set(t *testing.T) {
 					require.NoError(t, err)
 				}
 			},
-			after:          func(t *testing.T, c Cache) {},
-			expiration:     time.Minute,
-			key:            "key1",
-			val:            []byte("01234"),
-			max:            110,
-			wantLinkedList: list.NewLinkedListOf[string]([]string{"key1", "9", "8", "7", "6", "5", "4", "3", "2", "1", "0"}),
+			after:      func(t *testing.T, c Cache) {},
+			expiration: time.Minute,
+			key:        "key1",
+			val:        []byte("01234"),
+			max:        110,
+			wantKeys:   []string{"key1", "9", "8", "7", "6", "5", "4", "3", "2", "1", "0"},
 		},
 		{
 			name: "set success and need evicted",
@@ -49,12 +51,12 @@ func TestMaxMemoryCache_Set(t *testing.T) {
 					require.NoError(t, err)
 				}
 			},
-			after:          func(t *testing.T, c Cache) {},
-			expiration:     time.Minute,
-			key:            "key1",
-			val:            []byte("01234"),
-			max:            103,
-			wantLinkedList: list.NewLinkedListOf[string]([]string{"key1", "9", "8", "7", "6", "5", "4", "3", "2", "1"}),
+			after:      func(t *testing.T, c Cache) {},
+			expiration: time.Minute,
+			key:        "key1",
+			val:        []byte("01234"),
+			max:        103,
+			wantKeys:   []string{"key1", "9", "8", "7", "6", "5", "4", "3", "2", "1"},
 		},
 	}
 
@@ -66,17 +68,11 @@ func TestMaxMemoryCache_Set(t *testing.T) {
 
 			tc.before(t, maxMemCache)
 
-			// for i := 0; i <= 9; i++ {
-			// 	fmt.Println(maxMemCache.list.Get(i))
-			// }
-
 			ctx := context.Background()
 			err := maxMemCache.Set(ctx, tc.key, tc.val, time.Minute)
 
 			assert.Equal(t, tc.wantErr, err)
-			assert.Equal(t, tc.wantLinkedList.Len(), maxMemCache.list.Len())
-
-			assert.Equal(t, tc.wantLinkedList.AsSlice(), maxMemCache.list.AsSlice())
+			assert.Equal(t, tc.wantKeys, maxMemCache.keys())
 
 			tc.after(t, cache)
 		})
@@ -86,15 +82,15 @@ func TestMaxMemoryCache_Set(t *testing.T) {
 func TestMaxMemoryCache_Get(t *testing.T) {
 
 	testCases := []struct {
-		name           string
-		before         func(t *testing.T, c Cache)
-		after          func(t *testing.T, c Cache)
-		expiration     time.Duration
-		key            string
-		val            []byte
-		max            int64
-		wantLinkedList *list.LinkedList[string]
-		wantErr        error
+		name       string
+		before     func(t *testing.T, c Cache)
+		after      func(t *testing.T, c Cache)
+		expiration time.Duration
+		key        string
+		val        []byte
+		max        int64
+		wantKeys   []string
+		wantErr    error
 	}{
 		{
 			name: "get and move to head",
@@ -105,12 +101,12 @@ func TestMaxMemoryCache_Get(t *testing.T) {
 					require.NoError(t, err)
 				}
 			},
-			after:          func(t *testing.T, c Cache) {},
-			expiration:     time.Minute,
-			key:            "5",
-			val:            []byte("0123456789"),
-			max:            110,
-			wantLinkedList: list.NewLinkedListOf[string]([]string{"5", "9", "8", "7", "6", "4", "3", "2", "1", "0"}),
+			after:      func(t *testing.T, c Cache) {},
+			expiration: time.Minute,
+			key:        "5",
+			val:        []byte("0123456789"),
+			max:        110,
+			wantKeys:   []string{"5", "9", "8", "7", "6", "4", "3", "2", "1", "0"},
 		},
 	}
 
@@ -122,21 +118,224 @@ func TestMaxMemoryCache_Get(t *testing.T) {
 
 			tc.before(t, maxMemCache)
 
-			// for i := 0; i <= 9; i++ {
-			// 	fmt.Println(maxMemCache.list.Get(i))
-			// }
-
 			ctx := context.Background()
 			val, err := maxMemCache.Get(ctx, tc.key)
 
 			assert.Equal(t, tc.wantErr, err)
 			assert.Equal(t, tc.val, val)
 
-			assert.Equal(t, tc.wantLinkedList.AsSlice(), maxMemCache.list.AsSlice())
-
-			//fmt.Println(maxMemCache.list.AsSlice())
+			assert.Equal(t, tc.wantKeys, maxMemCache.keys())
 
 			tc.after(t, cache)
 		})
 	}
 }
+
+func TestMaxMemoryCache_Used(t *testing.T) {
+	cache := NewMemoryMapCache(time.Minute)
+	maxMemCache := NewMaxMemoryCache(20, cache)
+	ctx := context.Background()
+
+	require.NoError(t, maxMemCache.Set(ctx, "key1", []byte("0123456789"), time.Minute))
+	// 覆写只计算新的值
+	require.NoError(t, maxMemCache.Set(ctx, "key1", []byte("01234"), time.Minute))
+	assert.Equal(t, int64(5), maxMemCache.Used())
+
+	// 被装饰的 Cache 删除的 key 也要扣除
+	require.NoError(t, maxMemCache.Delete(ctx, "key1"))
+	assert.Equal(t, int64(0), maxMemCache.Used())
+	assert.Equal(t, []string{}, maxMemCache.keys())
+
+	err := maxMemCache.Set(ctx, "key2", make([]byte, 21), time.Minute)
+	assert.ErrorIs(t, err, errOverMaxMemory)
+	assert.Equal(t, int64(0), maxMemCache.Used())
+}
+
+func TestMaxMemoryCache_Concurrent(t *testing.T) {
+	cache := NewMemoryMapCache(time.Minute)
+	maxMemCache := NewMaxMemoryCache(1000, cache)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := strconv.Itoa((i*1000 + j) % 300)
+				switch j % 3 {
+				case 0:
+					_ = maxMemCache.Set(ctx, key, []byte("0123456789"), time.Minute)
+				case 1:
+					_, _ = maxMemCache.Get(ctx, key)
+				default:
+					_ = maxMemCache.Delete(ctx, key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, maxMemCache.Used(), int64(1000))
+	assert.Equal(t, int64(len(maxMemCache.keys())*10), maxMemCache.Used())
+}
+
+// yieldCache 写入之前让出 CPU，放大记账和写入之间的时间窗口，
+// 让出的次数和值的长度有关，先记账的不一定先写入
+type yieldCache struct {
+	*MemoryMapCache
+}
+
+func (c yieldCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	for i := 0; i < len(val)%4; i++ {
+		runtime.Gosched()
+	}
+	return c.MemoryMapCache.Set(ctx, key, val, expiration)
+}
+
+// TestMaxMemoryCache_ConcurrentSameKey 并发覆写同一个 key，
+// 记录的大小必须和最后写进去的值一致
+func TestMaxMemoryCache_ConcurrentSameKey(t *testing.T) {
+	cache := NewMemoryMapCache(time.Minute)
+	defer cache.Close()
+	maxMemCache := NewMaxMemoryCache(100, yieldCache{MemoryMapCache: cache})
+	ctx := context.Background()
+
+	// 每一轮都只有最后写入的值有效，多跑几轮让不同的交错都出现
+	for round := 0; round < 100; round++ {
+		var wg sync.WaitGroup
+		for i := 1; i <= 4; i++ {
+			wg.Add(1)
+			go func(size int) {
+				defer wg.Done()
+				_ = maxMemCache.Set(ctx, "key1", make([]byte, size), time.Minute)
+			}(round%10 + i)
+		}
+		wg.Wait()
+		val, err := maxMemCache.Get(ctx, "key1")
+		require.NoError(t, err)
+		require.Equal(t, int64(len(val)), maxMemCache.Used())
+	}
+}
+
+// gateCache 第一次删除 gated 的时候通知 deleting，然后等待 gate 关闭，
+// 用来让淘汰和覆写同一个 key 交错
+type gateCache struct {
+	*MemoryMapCache
+	gated    string
+	once     sync.Once
+	deleting chan struct{}
+	gate     chan struct{}
+}
+
+func (c *gateCache) Delete(ctx context.Context, key string) error {
+	if key == c.gated {
+		c.once.Do(func() {
+			close(c.deleting)
+			<-c.gate
+		})
+	}
+	return c.MemoryMapCache.Delete(ctx, key)
+}
+
+// TestMaxMemoryCache_SetEvictedKey 淘汰一个 key 的时候，同时覆写这个 key，
+// 新写入的值不能被淘汰删掉
+func TestMaxMemoryCache_SetEvictedKey(t *testing.T) {
+	cache := NewMemoryMapCache(time.Minute)
+	defer cache.Close()
+	gc := &gateCache{MemoryMapCache: cache, gated: "key1",
+		deleting: make(chan struct{}), gate: make(chan struct{})}
+	maxMemCache := NewMaxMemoryCache(10, gc)
+	ctx := context.Background()
+	require.NoError(t, maxMemCache.Set(ctx, "key1", []byte("0123456789"), time.Minute))
+
+	// 写入 key2 的时候淘汰 key1
+	evicted := make(chan error, 1)
+	go func() {
+		evicted <- maxMemCache.Set(ctx, "key2", []byte("abcdefghij"), time.Minute)
+	}()
+	<-gc.deleting
+
+	set := make(chan error, 1)
+	go func() {
+		set <- maxMemCache.Set(ctx, "key1", []byte("9876543210"), time.Minute)
+	}()
+	// 覆写要等淘汰结束，不然会被淘汰删掉
+	select {
+	case err := <-set:
+		t.Error("覆写没有等待淘汰结束")
+		set <- err
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(gc.gate)
+	require.NoError(t, <-evicted)
+	require.NoError(t, <-set)
+
+	val, err := maxMemCache.Get(ctx, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("9876543210"), val)
+	assert.Equal(t, int64(10), maxMemCache.Used())
+	assert.Equal(t, []string{"key1"}, maxMemCache.keys())
+}
+
+const benchKeys = 1000000
+
+func newBenchMaxMemoryCache(b *testing.B) (*MaxMemoryCache, []string) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	// 只能放下一半的 key，Set 的时候会一直淘汰
+	cache := NewMemoryMapCache(time.Hour)
+	b.Cleanup(func() {
+		_ = cache.Close()
+	})
+	maxMemCache := NewMaxMemoryCache(benchKeys/2*10, cache)
+	ctx := context.Background()
+	val := []byte("0123456789")
+	for _, key := range keys {
+		_ = maxMemCache.Set(ctx, key, val, time.Hour)
+	}
+	return maxMemCache, keys
+}
+
+func BenchmarkMaxMemoryCache_Set(b *testing.B) {
+	maxMemCache, keys := newBenchMaxMemoryCache(b)
+	ctx := context.Background()
+	val := []byte("0123456789")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = maxMemCache.Set(ctx, keys[i%benchKeys], val, time.Hour)
+	}
+}
+
+func BenchmarkMaxMemoryCache_Get(b *testing.B) {
+	maxMemCache, keys := newBenchMaxMemoryCache(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// 后一半 key 在缓存里面
+		_, _ = maxMemCache.Get(ctx, keys[benchKeys/2+i%(benchKeys/2)])
+	}
+}
+
+func BenchmarkMaxMemoryCache_Parallel(b *testing.B) {
+	maxMemCache, keys := newBenchMaxMemoryCache(b)
+	val := []byte("0123456789")
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		i := 0
+		for pb.Next() {
+			key := keys[(i*7919)%benchKeys]
+			if i%4 == 0 {
+				_ = maxMemCache.Set(ctx, key, val, time.Hour)
+			} else {
+				_, _ = maxMemCache.Get(ctx, key)
+			}
+			i++
+		}
+	})
+}
@@ -18,7 +18,7 @@ type MemoryMapCache struct {
 	data      map[string]*item
 	mutex     sync.RWMutex
 	close     chan struct{}
-	onEvicted func(key string, val []byte)
+	onEvicted []func(key string, val []byte)
 }
 
 func NewMemoryMapCache(interval time.Duration, opts ...MemoryMapCacheOption) *MemoryMapCache {
@@ -59,7 +59,7 @@ func NewMemoryMapCache(interval time.Duration, opts ...MemoryMapCacheOption) *Me
 
 func MemoryMapCacheWithEvictedCallback(fn func(key string, val []byte)) MemoryMapCacheOption {
 	return func(cache *MemoryMapCache) {
-		cache.onEvicted = fn
+		cache.onEvicted = append(cache.onEvicted, fn)
 	}
 }
 
@@ -129,7 +129,9 @@ func (c *MemoryMapCache) delete(key string) {
 		return
 	}
 	delete(c.data, key)
-	c.onEvicted(key, itm.val)
+	for _, fn := range c.onEvicted {
+		fn(key, itm.val)
+	}
 }
 
 // 我要是调用两次 close?
@@ -151,6 +153,10 @@ func (i *item) deadlineBefore(t time.Time) bool {
 	return !i.deadline.IsZero() && i.deadline.Before(t)
 }
 
+// OnEvicted 注册删除 key 的回调，可以注册多个，按照注册顺序调用
+// 回调的时候持有锁，所以回调里面不能再调用 MemoryMapCache 的方法
 func (c *MemoryMapCache) OnEvicted(fn func(key string, val []byte)) {
-	c.onEvicted = fn
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onEvicted = append(c.onEvicted, fn)
 }
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ReadThroughCache 缓存里面没有的时候，通过 LoadFunc 加载数据并且写到缓存里面
+// 同一个 key 的并发加载只会调用一次 LoadFunc
+// 使用的时候 Cache、LoadFunc 都必须赋值
+type ReadThroughCache struct {
+	Cache
+	// LoadFunc 从数据源加载数据，例如查询数据库
+	LoadFunc func(ctx context.Context, key string) ([]byte, error)
+	// Expiration 加载之后写到缓存里面的过期时间
+	Expiration time.Duration
+
+	g singleflight.Group
+}
+
+func (r *ReadThroughCache) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := r.Cache.Get(ctx, key)
+	if err == nil || !errors.Is(err, errKeyNotFound) {
+		return val, err
+	}
+	res, err, _ := r.g.Do(key, func() (interface{}, error) {
+		v, er := r.LoadFunc(ctx, key)
+		if er != nil {
+			return nil, fmt.Errorf("cache: 加载数据失败 %w", er)
+		}
+		// 刷新缓存失败不影响返回数据
+		_ = r.Cache.Set(ctx, key, v, r.Expiration)
+		return v, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.([]byte), nil
+}
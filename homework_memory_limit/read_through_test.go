@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadThroughCache_Get(t *testing.T) {
+	testCases := []struct {
+		name     string
+		before   func(c Cache)
+		loadFunc func(ctx context.Context, key string) ([]byte, error)
+		wantVal  []byte
+		wantErr  string
+		// wantCached 加载之后有没有写到缓存里面
+		wantCached bool
+	}{
+		{
+			name: "hit",
+			before: func(c Cache) {
+				_ = c.Set(context.Background(), "key1", []byte("cached"), time.Minute)
+			},
+			loadFunc: func(ctx context.Context, key string) ([]byte, error) {
+				return nil, errors.New("不应该加载")
+			},
+			wantVal:    []byte("cached"),
+			wantCached: true,
+		},
+		{
+			name:   "load",
+			before: func(c Cache) {},
+			loadFunc: func(ctx context.Context, key string) ([]byte, error) {
+				return []byte("loaded"), nil
+			},
+			wantVal:    []byte("loaded"),
+			wantCached: true,
+		},
+		{
+			name:   "load error",
+			before: func(c Cache) {},
+			loadFunc: func(ctx context.Context, key string) ([]byte, error) {
+				return nil, errors.New("mock error")
+			},
+			wantErr: "cache: 加载数据失败 mock error",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewMemoryMapCache(time.Minute)
+			defer c.Close()
+			tc.before(c)
+			rc := &ReadThroughCache{Cache: c, LoadFunc: tc.loadFunc, Expiration: time.Minute}
+			val, err := rc.Get(context.Background(), "key1")
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantVal, val)
+			_, err = c.Get(context.Background(), "key1")
+			assert.Equal(t, tc.wantCached, err == nil)
+		})
+	}
+}
+
+func TestReadThroughCache_Singleflight(t *testing.T) {
+	c := NewMemoryMapCache(time.Minute)
+	defer c.Close()
+	var cnt int32
+	release := make(chan struct{})
+	rc := &ReadThroughCache{
+		Cache: c,
+		LoadFunc: func(ctx context.Context, key string) ([]byte, error) {
+			atomic.AddInt32(&cnt, 1)
+			<-release
+			return []byte("loaded"), nil
+		},
+		Expiration: time.Minute,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := rc.Get(context.Background(), "key1")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("loaded"), val)
+		}()
+	}
+	// 等所有的 goroutine 都进入加载
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), cnt)
+}
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errUnexpectedReply = errors.New("cache: redis 返回了意料之外的响应")
+
+var _ Cache = &RedisCache{}
+
+type RedisCacheOption func(c *RedisCache)
+
+// RedisCacheWithDB 设置 keyspace 通知的数据库编号，默认 0
+// 需要和客户端连接的数据库一致
+func RedisCacheWithDB(db int) RedisCacheOption {
+	return func(c *RedisCache) {
+		c.db = db
+	}
+}
+
+// RedisCacheWithNotifyConfig 注册 OnEvicted 的时候，
+// 是否修改 notify-keyspace-events 开启通知，默认开启。
+// 只会补上缺少的 Egxe，服务端原有的配置会保留。
+// 云服务一般禁用了 CONFIG 命令，需要在控制台开启 Egxe 通知，然后在这里关闭
+func RedisCacheWithNotifyConfig(enable bool) RedisCacheOption {
+	return func(c *RedisCache) {
+		c.configNotify = enable
+	}
+}
+
+// RedisCache 基于 Redis 的 Cache 实现
+type RedisCache struct {
+	client       RedisClient
+	db           int
+	configNotify bool
+	// 订阅断开之后重新订阅的间隔，失败之后翻倍，最大 maxResubscribeInterval
+	resubscribeInterval time.Duration
+
+	mutex     sync.Mutex
+	onEvicted []func(key string, val []byte)
+	cancel    context.CancelFunc
+}
+
+func NewRedisCache(client RedisClient, opts ...RedisCacheOption) *RedisCache {
+	res := &RedisCache{
+		client:              client,
+		configNotify:        true,
+		resubscribeInterval: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// Set expiration 不大于 0 的时候永不过期
+func (r *RedisCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	args := []any{"SET", key, val}
+	if expiration > 0 {
+		ms := expiration.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+		args = append(args, "PX", ms)
+	}
+	reply, err := r.client.Do(ctx, args...)
+	if err != nil {
+		return err
+	}
+	if reply != "OK" {
+		return fmt.Errorf("%w, SET %s: %v", errUnexpectedReply, key, reply)
+	}
+	return nil
+}
+
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := r.client.Do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	return r.bytesReply(key, reply)
+}
+
+func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	_, err := r.client.Do(ctx, "DEL", key)
+	return err
+}
+
+// LoadAndDelete 使用 GETDEL，需要 Redis 6.2 以上
+func (r *RedisCache) LoadAndDelete(ctx context.Context, key string) ([]byte, error) {
+	reply, err := r.client.Do(ctx, "GETDEL", key)
+	if err != nil {
+		return nil, err
+	}
+	return r.bytesReply(key, reply)
+}
+
+func (r *RedisCache) bytesReply(key string, reply any) ([]byte, error) {
+	switch val := reply.(type) {
+	case nil:
+		return nil, fmt.Errorf("%w, key: %s", errKeyNotFound, key)
+	case []byte:
+		return val, nil
+	case string:
+		return []byte(val), nil
+	}
+	return nil, fmt.Errorf("%w, key: %s: %v", errUnexpectedReply, key, reply)
+}
+
+// OnEvicted 通过 keyspace 通知实现，key 被删除、过期或者淘汰的时候回调，
+// 回调拿不到值，val 是 nil。通知是异步的，而且 Redis 不保证送达
+// 客户端不支持订阅的话，注册的回调不会被调用
+func (r *RedisCache) OnEvicted(fn func(key string, val []byte)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onEvicted = append(r.onEvicted, fn)
+	if r.cancel != nil {
+		return
+	}
+	sub, ok := r.client.(RedisSubscriber)
+	if !ok {
+		return
+	}
+	r.watch(sub)
+}
+
+const maxResubscribeInterval = 10 * time.Second
+
+// watch 必须持有 mutex
+// 通知是尽力而为的，订阅失败不影响缓存本身，
+// 第一次订阅失败的时候，和订阅断开一样在后台不断重试，直到 Close
+func (r *RedisCache) watch(sub RedisSubscriber) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	msgs, err := r.subscribe(ctx, sub)
+	go func() {
+		if err != nil {
+			if msgs = r.resubscribe(ctx, sub); msgs == nil {
+				return
+			}
+		}
+		r.dispatch(ctx, sub, msgs)
+	}()
+}
+
+// subscribe 开启通知并且订阅 del、expired、evicted 三种事件
+func (r *RedisCache) subscribe(ctx context.Context, sub RedisSubscriber) (<-chan RedisMessage, error) {
+	if r.configNotify {
+		// 重新订阅一般是因为连接断开了，服务端可能重启过，配置需要重新检查
+		_ = r.configNotifyEvents(ctx)
+	}
+	prefix := "__keyevent@" + strconv.Itoa(r.db) + "__:"
+	return sub.Subscribe(ctx, prefix+"del", prefix+"expired", prefix+"evicted")
+}
+
+// dispatch 把通知分发给回调，订阅断开之后不断重试，直到 Close
+func (r *RedisCache) dispatch(ctx context.Context, sub RedisSubscriber, msgs <-chan RedisMessage) {
+	for msgs != nil {
+		for msg := range msgs {
+			r.mutex.Lock()
+			fns := r.onEvicted
+			r.mutex.Unlock()
+			for _, fn := range fns {
+				fn(msg.Payload, nil)
+			}
+		}
+		msgs = r.resubscribe(ctx, sub)
+	}
+}
+
+// resubscribe 按照退避时间重新订阅，直到成功。Close 之后返回 nil
+func (r *RedisCache) resubscribe(ctx context.Context, sub RedisSubscriber) <-chan RedisMessage {
+	interval := r.resubscribeInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+		if msgs, err := r.subscribe(ctx, sub); err == nil {
+			return msgs
+		}
+		if interval *= 2; interval > maxResubscribeInterval {
+			interval = maxResubscribeInterval
+		}
+	}
+}
+
+// configNotifyEvents 读取服务端的 notify-keyspace-events，补上缺少的标记
+func (r *RedisCache) configNotifyEvents(ctx context.Context) error {
+	reply, err := r.client.Do(ctx, "CONFIG", "GET", "notify-keyspace-events")
+	if err != nil {
+		return err
+	}
+	// 返回的是配置名和配置值组成的数组
+	vals, ok := reply.([]any)
+	if !ok || len(vals) != 2 {
+		return fmt.Errorf("%w, CONFIG GET: %v", errUnexpectedReply, reply)
+	}
+	current := string(toBytes(vals[1]))
+	flags := mergeNotifyFlags(current)
+	if flags == current {
+		return nil
+	}
+	_, err = r.client.Do(ctx, "CONFIG", "SET", "notify-keyspace-events", flags)
+	return err
+}
+
+// mergeNotifyFlags E 是 keyevent 通知，g 是 DEL 之类的通用命令，x 是过期，e 是淘汰
+// A 是包含了 g、x、e 在内的所有事件的别名
+func mergeNotifyFlags(current string) string {
+	res := current
+	if !strings.Contains(res, "E") {
+		res += "E"
+	}
+	if strings.Contains(res, "A") {
+		return res
+	}
+	for _, flag := range []string{"g", "x", "e"} {
+		if !strings.Contains(res, flag) {
+			res += flag
+		}
+	}
+	return res
+}
+
+// Close 取消 keyspace 通知的订阅，不会关闭客户端
+// 之后再调用 OnEvicted 会重新订阅
+func (r *RedisCache) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	return nil
+}
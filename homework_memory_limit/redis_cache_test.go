@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisCache(t *testing.T) {
+	srv := newRespServer(t)
+	client := NewRespClient(srv.addr())
+	defer client.Close()
+
+	testCases := []struct {
+		name    string
+		before  func(t *testing.T, c *RedisCache)
+		op      func(c *RedisCache) ([]byte, error)
+		wantVal []byte
+		wantErr error
+	}{
+		{
+			name:   "get not found",
+			before: func(t *testing.T, c *RedisCache) {},
+			op: func(c *RedisCache) ([]byte, error) {
+				return c.Get(context.Background(), "key1")
+			},
+			wantErr: errKeyNotFound,
+		},
+		{
+			name: "get",
+			before: func(t *testing.T, c *RedisCache) {
+				require.NoError(t, c.Set(context.Background(), "key1", []byte("value1"), time.Minute))
+			},
+			op: func(c *RedisCache) ([]byte, error) {
+				return c.Get(context.Background(), "key1")
+			},
+			wantVal: []byte("value1"),
+		},
+		{
+			name: "expired",
+			before: func(t *testing.T, c *RedisCache) {
+				require.NoError(t, c.Set(context.Background(), "key1", []byte("value1"), time.Millisecond))
+				time.Sleep(5 * time.Millisecond)
+			},
+			op: func(c *RedisCache) ([]byte, error) {
+				return c.Get(context.Background(), "key1")
+			},
+			wantErr: errKeyNotFound,
+		},
+		{
+			name: "delete",
+			before: func(t *testing.T, c *RedisCache) {
+				require.NoError(t, c.Set(context.Background(), "key1", []byte("value1"), 0))
+				require.NoError(t, c.Delete(context.Background(), "key1"))
+			},
+			op: func(c *RedisCache) ([]byte, error) {
+				return c.Get(context.Background(), "key1")
+			},
+			wantErr: errKeyNotFound,
+		},
+		{
+			name: "load and delete",
+			before: func(t *testing.T, c *RedisCache) {
+				require.NoError(t, c.Set(context.Background(), "key1", []byte("value1"), time.Minute))
+				val, err := c.LoadAndDelete(context.Background(), "key1")
+				require.NoError(t, err)
+				assert.Equal(t, []byte("value1"), val)
+			},
+			op: func(c *RedisCache) ([]byte, error) {
+				return c.LoadAndDelete(context.Background(), "key1")
+			},
+			wantErr: errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewRedisCache(client)
+			tc.before(t, c)
+			val, err := tc.op(c)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestRespClient_Error(t *testing.T) {
+	srv := newRespServer(t)
+	client := NewRespClient(srv.addr())
+	ctx := context.Background()
+
+	_, err := client.Do(ctx, "FOO")
+	assert.Equal(t, RedisError("ERR unknown command 'FOO'"), err)
+	// Redis 返回错误之后连接还可以继续使用
+	reply, err := client.Do(ctx, "PING")
+	require.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+
+	_, err = client.Do(ctx, "SET", "key1", 1.5)
+	assert.Error(t, err)
+
+	require.NoError(t, client.Close())
+	_, err = client.Do(ctx, "PING")
+	assert.Equal(t, errClientClosed, err)
+}
+
+func TestRedisCache_OnEvicted(t *testing.T) {
+	srv := newRespServer(t)
+	client := NewRespClient(srv.addr())
+	defer client.Close()
+	c := NewRedisCache(client)
+	defer c.Close()
+
+	evicted := make(chan string, 10)
+	c.OnEvicted(func(key string, val []byte) {
+		evicted <- key
+	})
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "key1", []byte("value1"), 0))
+	require.NoError(t, c.Set(ctx, "key2", []byte("value2"), time.Millisecond))
+	require.NoError(t, c.Set(ctx, "key3", []byte("value3"), 0))
+
+	// key1 被删除，key2 过期，两个通知的顺序不确定
+	require.NoError(t, c.Delete(ctx, "key1"))
+	assert.ElementsMatch(t, []string{"key1", "key2"},
+		[]string{waitKey(t, evicted), waitKey(t, evicted)})
+	// 超过最大内存被淘汰
+	srv.evict("key3")
+	assert.Equal(t, "key3", waitKey(t, evicted))
+}
+
+func TestRedisCache_NotifyConfig(t *testing.T) {
+	srv := newRespServer(t)
+	client := NewRespClient(srv.addr())
+	defer client.Close()
+	ctx := context.Background()
+	// 服务端已经开启了 keyspace 通知，不能被覆盖
+	_, err := client.Do(ctx, "CONFIG", "SET", "notify-keyspace-events", "KA")
+	require.NoError(t, err)
+
+	c := NewRedisCache(client)
+	defer c.Close()
+	c.OnEvicted(func(key string, val []byte) {})
+	assert.Equal(t, "KAE", srv.notifyConfig())
+
+	testCases := []struct {
+		current string
+		want    string
+	}{
+		{current: "", want: "Egxe"},
+		{current: "Ex", want: "Exge"},
+		{current: "KEA", want: "KEA"},
+		{current: "Kl", want: "KlEgxe"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, mergeNotifyFlags(tc.current), tc.current)
+	}
+}
+
+func TestRedisCache_Resubscribe(t *testing.T) {
+	srv := newRespServer(t)
+	client := NewRespClient(srv.addr())
+	defer client.Close()
+	c := NewRedisCache(client)
+	c.resubscribeInterval = time.Millisecond
+	defer c.Close()
+
+	evicted := make(chan string, 10)
+	c.OnEvicted(func(key string, val []byte) {
+		evicted <- key
+	})
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "key1", []byte("value1"), 0))
+
+	// 订阅的连接断开之后重新订阅，回调继续生效
+	srv.dropSubscribers()
+	assert.Eventually(t, func() bool {
+		return srv.subscribers("__keyevent@0__:del") == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, c.Delete(ctx, "key1"))
+	assert.Equal(t, "key1", waitKey(t, evicted))
+}
+
+// TestRedisCache_SubscribeRetry 第一次订阅失败之后在后台重试，不需要再次注册回调
+func TestRedisCache_SubscribeRetry(t *testing.T) {
+	srv := newRespServer(t)
+	client := NewRespClient(srv.addr())
+	defer client.Close()
+	sub := &flakySubscriber{RespClient: client, failures: 2}
+	c := NewRedisCache(sub)
+	c.resubscribeInterval = time.Millisecond
+
+	evicted := make(chan string, 10)
+	c.OnEvicted(func(key string, val []byte) {
+		evicted <- key
+	})
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "key1", []byte("value1"), 0))
+	assert.Eventually(t, func() bool {
+		return srv.subscribers("__keyevent@0__:del") == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, c.Delete(ctx, "key1"))
+	assert.Equal(t, "key1", waitKey(t, evicted))
+
+	// Close 之后再注册回调，会重新订阅
+	require.NoError(t, c.Close())
+	assert.Eventually(t, func() bool {
+		return srv.subscribers("__keyevent@0__:del") == 0
+	}, time.Second, time.Millisecond)
+	c.OnEvicted(func(key string, val []byte) {})
+	defer c.Close()
+	assert.Eventually(t, func() bool {
+		return srv.subscribers("__keyevent@0__:del") == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, c.Set(ctx, "key2", []byte("value2"), 0))
+	require.NoError(t, c.Delete(ctx, "key2"))
+	assert.Equal(t, "key2", waitKey(t, evicted))
+}
+
+// flakySubscriber 前几次订阅失败，模拟 Redis 短暂不可用
+type flakySubscriber struct {
+	*RespClient
+	mutex    sync.Mutex
+	failures int
+}
+
+func (f *flakySubscriber) Subscribe(ctx context.Context, channels ...string) (<-chan RedisMessage, error) {
+	f.mutex.Lock()
+	fail := f.failures > 0
+	f.failures--
+	f.mutex.Unlock()
+	if fail {
+		return nil, errors.New("mock error")
+	}
+	return f.RespClient.Subscribe(ctx, channels...)
+}
+
+func waitKey(t *testing.T, ch <-chan string) string {
+	select {
+	case key := <-ch:
+		return key
+	case <-time.After(time.Second):
+		t.Fatal("没有收到通知")
+		return ""
+	}
+}
+
+// doOnlyClient 不支持订阅的客户端
+type doOnlyClient struct {
+	reply any
+}
+
+func (d doOnlyClient) Do(ctx context.Context, args ...any) (any, error) {
+	return d.reply, nil
+}
+
+func TestRedisCache_UnexpectedReply(t *testing.T) {
+	c := NewRedisCache(doOnlyClient{reply: int64(1)})
+	// 不支持订阅的时候什么也不做
+	c.OnEvicted(func(key string, val []byte) {})
+
+	ctx := context.Background()
+	assert.ErrorIs(t, c.Set(ctx, "key1", []byte("value1"), time.Minute), errUnexpectedReply)
+	_, err := c.Get(ctx, "key1")
+	assert.ErrorIs(t, err, errUnexpectedReply)
+}
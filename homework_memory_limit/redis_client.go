@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var errClientClosed = errors.New("cache: redis 客户端已经关闭")
+
+// RedisClient 执行 Redis 命令，响应的类型和 readReply 一样，
+// Redis 返回的错误以 RedisError 的形式作为 error 返回
+// 可以用 go-redis 之类的客户端适配这个接口
+type RedisClient interface {
+	Do(ctx context.Context, args ...any) (any, error)
+}
+
+// RedisSubscriber 支持发布订阅的客户端，RedisCache 用它接收 keyspace 通知
+type RedisSubscriber interface {
+	// Subscribe 订阅频道，ctx 结束之后取消订阅并且关闭返回的 channel
+	Subscribe(ctx context.Context, channels ...string) (<-chan RedisMessage, error)
+}
+
+type RedisMessage struct {
+	Channel string
+	Payload string
+}
+
+var (
+	_ RedisClient     = &RespClient{}
+	_ RedisSubscriber = &RespClient{}
+)
+
+type RespClientOption func(c *RespClient)
+
+// RespClientWithMaxIdle 设置最多保留的空闲连接数量，默认 8
+func RespClientWithMaxIdle(n int) RespClientOption {
+	return func(c *RespClient) {
+		c.maxIdle = n
+	}
+}
+
+// RespClientWithDialTimeout 设置建立连接的超时时间，默认 3 秒
+func RespClientWithDialTimeout(timeout time.Duration) RespClientOption {
+	return func(c *RespClient) {
+		c.dialTimeout = timeout
+	}
+}
+
+// RespClient 使用 RESP 协议的最小 Redis 客户端，带一个简单的连接池
+// 不支持 pipeline 和集群
+type RespClient struct {
+	addr        string
+	maxIdle     int
+	dialTimeout time.Duration
+
+	mutex  sync.Mutex
+	idle   []*respConn
+	closed bool
+}
+
+type respConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func NewRespClient(addr string, opts ...RespClientOption) *RespClient {
+	res := &RespClient{
+		addr:        addr,
+		maxIdle:     8,
+		dialTimeout: 3 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+func (c *RespClient) Do(ctx context.Context, args ...any) (any, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, args)
+	if err != nil {
+		// 连接的状态不确定了，不能再放回去
+		_ = conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	if rerr, ok := reply.(RedisError); ok {
+		return nil, rerr
+	}
+	return reply, nil
+}
+
+// Subscribe 每次订阅都使用一个新的连接
+func (c *RespClient) Subscribe(ctx context.Context, channels ...string) (<-chan RedisMessage, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]any, 0, len(channels)+1)
+	args = append(args, "SUBSCRIBE")
+	for _, ch := range channels {
+		args = append(args, ch)
+	}
+	if err = conn.send(ctx, args); err == nil {
+		// 每一个频道都有一个订阅成功的响应
+		for range channels {
+			if _, err = readReply(conn.r); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	res := make(chan RedisMessage, 64)
+	go func() {
+		<-ctx.Done()
+		// 关闭连接让下面的读取返回
+		_ = conn.Close()
+	}()
+	go func() {
+		defer close(res)
+		for {
+			reply, err := readReply(conn.r)
+			if err != nil {
+				return
+			}
+			msg, ok := reply.([]any)
+			if !ok || len(msg) != 3 || string(toBytes(msg[0])) != "message" {
+				continue
+			}
+			select {
+			case res <- RedisMessage{Channel: string(toBytes(msg[1])), Payload: string(toBytes(msg[2]))}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return res, nil
+}
+
+// Close 关闭所有空闲的连接，之后不能再使用
+func (c *RespClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	for _, conn := range c.idle {
+		_ = conn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (c *RespClient) get(ctx context.Context) (*respConn, error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, errClientClosed
+	}
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mutex.Unlock()
+		return conn, nil
+	}
+	c.mutex.Unlock()
+	return c.dial(ctx)
+}
+
+func (c *RespClient) put(conn *respConn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed || len(c.idle) >= c.maxIdle {
+		_ = conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+func (c *RespClient) dial(ctx context.Context) (*respConn, error) {
+	d := net.Dialer{Timeout: c.dialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &respConn{
+		Conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}, nil
+}
+
+// send 发送命令，ctx 有超时时间的话同时作为连接的超时时间
+func (conn *respConn) send(ctx context.Context, args []any) error {
+	dl, _ := ctx.Deadline()
+	if err := conn.SetDeadline(dl); err != nil {
+		return err
+	}
+	if err := writeCommand(conn.w, args); err != nil {
+		return err
+	}
+	return conn.w.Flush()
+}
+
+func (conn *respConn) do(ctx context.Context, args []any) (any, error) {
+	if err := conn.send(ctx, args); err != nil {
+		return nil, err
+	}
+	return readReply(conn.r)
+}
+
+// toBytes 批量字符串是 []byte，简单字符串是 string
+func toBytes(v any) []byte {
+	switch val := v.(type) {
+	case []byte:
+		return val
+	case string:
+		return []byte(val)
+	}
+	return []byte(fmt.Sprint(v))
+}
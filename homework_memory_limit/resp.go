@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var errInvalidReply = errors.New("cache: 非法的 RESP 响应")
+
+// RedisError Redis 返回的错误，例如 WRONGTYPE
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// writeCommand 把命令编码成 RESP 数组，参数支持 string、[]byte 和整数
+func writeCommand(w *bufio.Writer, args []any) error {
+	if err := writeHeader(w, '*', int64(len(args))); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		var bs []byte
+		switch v := arg.(type) {
+		case string:
+			bs = []byte(v)
+		case []byte:
+			bs = v
+		case int:
+			bs = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			bs = strconv.AppendInt(nil, v, 10)
+		default:
+			return fmt.Errorf("cache: 不支持的参数类型 %T", arg)
+		}
+		if err := writeBulk(w, bs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHeader(w *bufio.Writer, prefix byte, n int64) error {
+	_ = w.WriteByte(prefix)
+	_, _ = w.WriteString(strconv.FormatInt(n, 10))
+	_, err := w.WriteString("\r\n")
+	return err
+}
+
+func writeBulk(w *bufio.Writer, bs []byte) error {
+	if err := writeHeader(w, '$', int64(len(bs))); err != nil {
+		return err
+	}
+	_, _ = w.Write(bs)
+	_, err := w.WriteString("\r\n")
+	return err
+}
+
+// readReply 读取一个 RESP 响应：
+// 简单字符串是 string，整数是 int64，批量字符串是 []byte，数组是 []any，
+// 空的批量字符串和空数组是 nil，错误是 RedisError
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errInvalidReply
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return RedisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 {
+			return nil, errInvalidReply
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		bs := make([]byte, n+2)
+		if _, err = io.ReadFull(r, bs); err != nil {
+			return nil, err
+		}
+		return bs[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 {
+			return nil, errInvalidReply
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		res := make([]any, n)
+		for i := range res {
+			if res[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("%w, %q", errInvalidReply, line)
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errInvalidReply
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,303 @@
+package cache
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// respServer 测试用的迷你 Redis，只支持 RedisCache 用到的命令和 keyspace 通知
+type respServer struct {
+	listener net.Listener
+
+	mutex  sync.Mutex
+	data   map[string]*item
+	notify string
+	// subs 频道和订阅了它的连接
+	subs   map[string][]*serverConn
+	conns  map[*serverConn]struct{}
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+type serverConn struct {
+	net.Conn
+	// 发布通知和响应命令可能在不同的 goroutine，写入需要加锁
+	mutex sync.Mutex
+	w     *bufio.Writer
+}
+
+func newRespServer(t *testing.T) *respServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &respServer{
+		listener: l,
+		data:     make(map[string]*item, 16),
+		subs:     make(map[string][]*serverConn, 4),
+		conns:    make(map[*serverConn]struct{}, 4),
+		closed:   make(chan struct{}),
+	}
+	s.wg.Add(2)
+	go s.accept()
+	go s.sweep()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *respServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *respServer) close() {
+	close(s.closed)
+	_ = s.listener.Close()
+	s.mutex.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+}
+
+// evict 模拟超过 maxmemory 之后淘汰 key
+func (s *respServer) evict(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.data[key]; ok {
+		delete(s.data, key)
+		s.publish("evicted", key)
+	}
+}
+
+// notifyConfig 当前的 notify-keyspace-events
+func (s *respServer) notifyConfig() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.notify
+}
+
+// dropSubscribers 模拟网络故障，断开所有订阅的连接
+func (s *respServer) dropSubscribers() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for ch, conns := range s.subs {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		delete(s.subs, ch)
+	}
+}
+
+// subscribers 订阅了频道的连接数量
+func (s *respServer) subscribers(ch string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.subs[ch])
+}
+
+func (s *respServer) accept() {
+	defer s.wg.Done()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := &serverConn{Conn: c, w: bufio.NewWriter(c)}
+		s.mutex.Lock()
+		s.conns[conn] = struct{}{}
+		s.mutex.Unlock()
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// sweep 主动清理过期的 key
+func (s *respServer) sweep() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.mutex.Lock()
+			for key, itm := range s.data {
+				if itm.deadlineBefore(now) {
+					delete(s.data, key)
+					s.publish("expired", key)
+				}
+			}
+			s.mutex.Unlock()
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+func (s *respServer) serve(conn *serverConn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		// 连接断开之后，订阅也就取消了
+		for ch, conns := range s.subs {
+			for i, c := range conns {
+				if c == conn {
+					s.subs[ch] = append(conns[:i:i], conns[i+1:]...)
+					break
+				}
+			}
+		}
+		s.mutex.Unlock()
+		_ = conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		parts, ok := req.([]any)
+		if !ok || len(parts) == 0 {
+			return
+		}
+		args := make([]string, len(parts))
+		for i, p := range parts {
+			args[i] = string(toBytes(p))
+		}
+		if strings.ToUpper(args[0]) == "SUBSCRIBE" {
+			s.subscribe(conn, args[1:])
+			continue
+		}
+		s.mutex.Lock()
+		reply := s.handle(args)
+		s.mutex.Unlock()
+		if conn.write(reply) != nil {
+			return
+		}
+	}
+}
+
+// handle 必须持有 mutex
+func (s *respServer) handle(args []string) any {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "PONG"
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			return RedisError("ERR syntax error")
+		}
+		itm := &item{val: []byte(args[2])}
+		if len(args) == 5 {
+			ms, err := strconv.Atoi(args[4])
+			if err != nil || strings.ToUpper(args[3]) != "PX" {
+				return RedisError("ERR syntax error")
+			}
+			itm.deadline = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		s.data[args[1]] = itm
+		return "OK"
+	case "GET":
+		if itm, ok := s.load(args[1]); ok {
+			return itm.val
+		}
+		return nil
+	case "GETDEL":
+		itm, ok := s.load(args[1])
+		if !ok {
+			return nil
+		}
+		delete(s.data, args[1])
+		s.publish("del", args[1])
+		return itm.val
+	case "DEL":
+		var cnt int64
+		for _, key := range args[1:] {
+			if _, ok := s.load(key); ok {
+				delete(s.data, key)
+				s.publish("del", key)
+				cnt++
+			}
+		}
+		return cnt
+	case "CONFIG":
+		if len(args) == 4 && strings.ToUpper(args[1]) == "SET" &&
+			args[2] == "notify-keyspace-events" {
+			s.notify = args[3]
+			return "OK"
+		}
+		if len(args) == 3 && strings.ToUpper(args[1]) == "GET" &&
+			args[2] == "notify-keyspace-events" {
+			return []any{[]byte(args[2]), []byte(s.notify)}
+		}
+		return RedisError("ERR unsupported CONFIG")
+	}
+	return RedisError("ERR unknown command '" + args[0] + "'")
+}
+
+// load 访问的时候删除过期的 key
+func (s *respServer) load(key string) (*item, bool) {
+	itm, ok := s.data[key]
+	if !ok {
+		return nil, false
+	}
+	if itm.deadlineBefore(time.Now()) {
+		delete(s.data, key)
+		s.publish("expired", key)
+		return nil, false
+	}
+	return itm, true
+}
+
+func (s *respServer) subscribe(conn *serverConn, channels []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, ch := range channels {
+		s.subs[ch] = append(s.subs[ch], conn)
+		_ = conn.write([]any{[]byte("subscribe"), []byte(ch), int64(i + 1)})
+	}
+}
+
+// publish 必须持有 mutex，按照 notify-keyspace-events 的配置发布 keyevent 通知
+func (s *respServer) publish(event string, key string) {
+	flags := map[string]string{"del": "g", "expired": "x", "evicted": "e"}
+	if !strings.Contains(s.notify, "E") || !strings.Contains(s.notify, flags[event]) {
+		return
+	}
+	ch := "__keyevent@0__:" + event
+	for _, conn := range s.subs[ch] {
+		_ = conn.write([]any{[]byte("message"), []byte(ch), []byte(key)})
+	}
+}
+
+func (conn *serverConn) write(reply any) error {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	writeReply(conn.w, reply)
+	return conn.w.Flush()
+}
+
+func writeReply(w *bufio.Writer, reply any) {
+	switch v := reply.(type) {
+	case nil:
+		_, _ = w.WriteString("$-1\r\n")
+	case string:
+		_, _ = w.WriteString("+" + v + "\r\n")
+	case RedisError:
+		_, _ = w.WriteString("-" + string(v) + "\r\n")
+	case int64:
+		_ = writeHeader(w, ':', v)
+	case []byte:
+		_ = writeBulk(w, v)
+	case []any:
+		_ = writeHeader(w, '*', int64(len(v)))
+		for _, elem := range v {
+			writeReply(w, elem)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var errCacheClosed = errors.New("cache: 重复关闭")
+
+const (
+	defaultShards     = 64
+	defaultSweepLimit = 100
+	// sweepRounds 一次清理中，一个分片最多连续检查的轮数
+	sweepRounds = 4
+)
+
+type ShardedCacheOption func(cache *ShardedCache)
+
+// ShardedCache 分片的本地缓存，key 按照哈希值分散到多个分片，
+// 每个分片有自己的锁，不同分片上的读写不会互相阻塞。
+// 过期清理也是逐个分片进行的，每次只检查一个分片的少量 key，
+// 不会像 MemoryMapCache 那样长时间持有全局的写锁
+type ShardedCache struct {
+	shards     []*cacheShard
+	mask       uint64
+	sweepLimit int
+	onEvicted  []func(key string, val []byte)
+
+	close     chan struct{}
+	closeOnce sync.Once
+}
+
+type cacheShard struct {
+	mutex sync.RWMutex
+	data  map[string]*item
+}
+
+// ShardedCacheWithShards 设置分片数量，会向上取整到 2 的幂，默认 64
+func ShardedCacheWithShards(n int) ShardedCacheOption {
+	return func(cache *ShardedCache) {
+		shards := 1
+		for shards < n {
+			shards <<= 1
+		}
+		cache.shards = make([]*cacheShard, shards)
+	}
+}
+
+// ShardedCacheWithSweepLimit 设置每一轮清理中一个分片最多检查的 key 的数量，默认 100
+// 检查的 key 里面超过四分之一已经过期的话，会继续检查这个分片
+func ShardedCacheWithSweepLimit(limit int) ShardedCacheOption {
+	return func(cache *ShardedCache) {
+		cache.sweepLimit = limit
+	}
+}
+
+func ShardedCacheWithEvictedCallback(fn func(key string, val []byte)) ShardedCacheOption {
+	return func(cache *ShardedCache) {
+		cache.onEvicted = append(cache.onEvicted, fn)
+	}
+}
+
+// NewShardedCache interval 是清理过期 key 的间隔
+func NewShardedCache(interval time.Duration, opts ...ShardedCacheOption) *ShardedCache {
+	res := &ShardedCache{
+		shards:     make([]*cacheShard, defaultShards),
+		sweepLimit: defaultSweepLimit,
+		close:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	for i := range res.shards {
+		res.shards[i] = &cacheShard{data: make(map[string]*item, 16)}
+	}
+	res.mask = uint64(len(res.shards) - 1)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case t := <-ticker.C:
+				res.sweep(t)
+			case <-res.close:
+				return
+			}
+		}
+	}()
+	return res
+}
+
+func (c *ShardedCache) shard(key string) *cacheShard {
+	return c.shards[hashKey(key)&c.mask]
+}
+
+func (c *ShardedCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	var dl time.Time
+	if expiration > 0 {
+		dl = time.Now().Add(expiration)
+	}
+	s := c.shard(key)
+	s.mutex.Lock()
+	s.data[key] = &item{
+		val:      val,
+		deadline: dl,
+	}
+	s.mutex.Unlock()
+	return nil
+}
+
+func (c *ShardedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	s := c.shard(key)
+	s.mutex.RLock()
+	res, ok := s.data[key]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w, key: %s", errKeyNotFound, key)
+	}
+	now := time.Now()
+	if res.deadlineBefore(now) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		// double check，其它 goroutine 可能已经覆写了
+		res, ok = s.data[key]
+		if !ok || res.deadlineBefore(now) {
+			c.delete(s, key)
+			return nil, fmt.Errorf("%w, key: %s", errKeyNotFound, key)
+		}
+	}
+	return res.val, nil
+}
+
+func (c *ShardedCache) Delete(ctx context.Context, key string) error {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c.delete(s, key)
+	return nil
+}
+
+func (c *ShardedCache) LoadAndDelete(ctx context.Context, key string) ([]byte, error) {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res, ok := s.data[key]
+	if !ok {
+		return nil, fmt.Errorf("%w, key: %s", errKeyNotFound, key)
+	}
+	c.delete(s, key)
+	if res.deadlineBefore(time.Now()) {
+		return nil, fmt.Errorf("%w, key: %s", errKeyNotFound, key)
+	}
+	return res.val, nil
+}
+
+// OnEvicted 和 MemoryMapCache 一样，可以注册多个回调，回调的时候持有分片的锁
+// 回调是在分片的锁里面读取的，所以注册的时候要锁住所有的分片
+func (c *ShardedCache) OnEvicted(fn func(key string, val []byte)) {
+	for _, s := range c.shards {
+		s.mutex.Lock()
+	}
+	c.onEvicted = append(c.onEvicted, fn)
+	for _, s := range c.shards {
+		s.mutex.Unlock()
+	}
+}
+
+func (c *ShardedCache) Close() error {
+	err := errCacheClosed
+	c.closeOnce.Do(func() {
+		close(c.close)
+		err = nil
+	})
+	return err
+}
+
+// Len 所有分片的 key 的数量，包括已经过期但是还没有被清理的
+func (c *ShardedCache) Len() int {
+	res := 0
+	for _, s := range c.shards {
+		s.mutex.RLock()
+		res += len(s.data)
+		s.mutex.RUnlock()
+	}
+	return res
+}
+
+// delete 必须持有分片的写锁
+func (c *ShardedCache) delete(s *cacheShard, key string) {
+	itm, ok := s.data[key]
+	if !ok {
+		return
+	}
+	delete(s.data, key)
+	for _, fn := range c.onEvicted {
+		fn(key, itm.val)
+	}
+}
+
+// sweep 逐个分片清理过期的 key，同一时间只持有一个分片的锁
+func (c *ShardedCache) sweep(now time.Time) {
+	for _, s := range c.shards {
+		for round := 0; round < sweepRounds; round++ {
+			// 过期的 key 不多，这个分片就不用继续检查了
+			if c.sweepShard(s, now)*4 <= c.sweepLimit {
+				break
+			}
+		}
+	}
+}
+
+// sweepShard 检查分片里面最多 sweepLimit 个 key，返回过期的数量
+// map 的遍历顺序是随机的，所以每一轮检查的都是不同的 key
+func (c *ShardedCache) sweepShard(s *cacheShard, now time.Time) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	checked, expired := 0, 0
+	for key, itm := range s.data {
+		if checked >= c.sweepLimit {
+			break
+		}
+		if itm.deadlineBefore(now) {
+			c.delete(s, key)
+			expired++
+		}
+		checked++
+	}
+	return expired
+}
@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedCache(t *testing.T) {
+	testCases := []struct {
+		name    string
+		before  func(c *ShardedCache)
+		key     string
+		wantVal []byte
+		wantErr error
+	}{
+		{
+			name:    "not found",
+			before:  func(c *ShardedCache) {},
+			key:     "key1",
+			wantErr: errKeyNotFound,
+		},
+		{
+			name: "found",
+			before: func(c *ShardedCache) {
+				require.NoError(t, c.Set(context.Background(), "key1", []byte("value1"), time.Minute))
+			},
+			key:     "key1",
+			wantVal: []byte("value1"),
+		},
+		{
+			name: "no expiration",
+			before: func(c *ShardedCache) {
+				require.NoError(t, c.Set(context.Background(), "key1", []byte("value1"), 0))
+			},
+			key:     "key1",
+			wantVal: []byte("value1"),
+		},
+		{
+			name: "expired",
+			before: func(c *ShardedCache) {
+				require.NoError(t, c.Set(context.Background(), "key1", []byte("value1"), time.Millisecond))
+				time.Sleep(2 * time.Millisecond)
+			},
+			key:     "key1",
+			wantErr: errKeyNotFound,
+		},
+		{
+			name: "deleted",
+			before: func(c *ShardedCache) {
+				require.NoError(t, c.Set(context.Background(), "key1", []byte("value1"), time.Minute))
+				require.NoError(t, c.Delete(context.Background(), "key1"))
+			},
+			key:     "key1",
+			wantErr: errKeyNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewShardedCache(time.Minute, ShardedCacheWithShards(4))
+			defer c.Close()
+			tc.before(c)
+			val, err := c.Get(context.Background(), tc.key)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestShardedCache_LoadAndDelete(t *testing.T) {
+	c := NewShardedCache(time.Minute)
+	defer c.Close()
+	ctx := context.Background()
+
+	_, err := c.LoadAndDelete(ctx, "key1")
+	assert.ErrorIs(t, err, errKeyNotFound)
+
+	require.NoError(t, c.Set(ctx, "key1", []byte("value1"), time.Minute))
+	val, err := c.LoadAndDelete(ctx, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value1"), val)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestShardedCache_Sweep(t *testing.T) {
+	var mutex sync.Mutex
+	evicted := make(map[string]struct{})
+	c := NewShardedCache(10*time.Millisecond,
+		ShardedCacheWithShards(3),
+		ShardedCacheWithSweepLimit(10),
+		ShardedCacheWithEvictedCallback(func(key string, val []byte) {
+			mutex.Lock()
+			evicted[key] = struct{}{}
+			mutex.Unlock()
+		}))
+	defer c.Close()
+	assert.Len(t, c.shards, 4)
+
+	ctx := context.Background()
+	for i := 0; i < 200; i++ {
+		require.NoError(t, c.Set(ctx, strconv.Itoa(i), []byte("val"), 5*time.Millisecond))
+	}
+	require.NoError(t, c.Set(ctx, "forever", []byte("val"), 0))
+
+	// 每个分片一轮检查 10 个，过期的多的话继续检查，几次之后就能清理完
+	assert.Eventually(t, func() bool {
+		return c.Len() == 1
+	}, time.Second, 10*time.Millisecond)
+	mutex.Lock()
+	assert.Len(t, evicted, 200)
+	mutex.Unlock()
+	_, err := c.Get(ctx, "forever")
+	assert.NoError(t, err)
+}
+
+// TestShardedCache_OnEvicted 清理过期 key 的同时注册回调，用 -race 检查
+func TestShardedCache_OnEvicted(t *testing.T) {
+	c := NewShardedCache(time.Millisecond, ShardedCacheWithShards(4))
+	defer c.Close()
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, c.Set(ctx, strconv.Itoa(i), []byte("val"), time.Millisecond))
+	}
+	var mutex sync.Mutex
+	cnt := 0
+	for i := 0; i < 10; i++ {
+		c.OnEvicted(func(key string, val []byte) {
+			mutex.Lock()
+			cnt++
+			mutex.Unlock()
+		})
+		time.Sleep(time.Millisecond)
+	}
+	assert.Eventually(t, func() bool {
+		return c.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+	mutex.Lock()
+	assert.Greater(t, cnt, 0)
+	mutex.Unlock()
+}
+
+func TestShardedCache_Close(t *testing.T) {
+	c := NewShardedCache(time.Minute)
+	assert.NoError(t, c.Close())
+	assert.Equal(t, errCacheClosed, c.Close())
+}
+
+func TestShardedCache_MaxMemory(t *testing.T) {
+	c := NewShardedCache(time.Minute)
+	defer c.Close()
+	maxMemCache := NewMaxMemoryCache(20, c)
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, maxMemCache.Set(ctx, key, []byte("0123456789"), time.Minute))
+	}
+	_, err := c.Get(ctx, "a")
+	assert.ErrorIs(t, err, errKeyNotFound)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, int64(20), maxMemCache.Used())
+}
+
+// 读多写少，同时后台在清理过期的 key
+func benchmarkParallel(b *testing.B, c Cache) {
+	ctx := context.Background()
+	const keys = 100000
+	for i := 0; i < keys; i++ {
+		// 一半的 key 很快过期，让清理的 goroutine 有事可做
+		expiration := time.Hour
+		if i%2 == 0 {
+			expiration = time.Millisecond
+		}
+		_ = c.Set(ctx, strconv.Itoa(i), []byte("0123456789"), expiration)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := strconv.Itoa(i % keys)
+			if i%10 == 0 {
+				_ = c.Set(ctx, key, []byte("0123456789"), time.Hour)
+			} else {
+				_, _ = c.Get(ctx, key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkParallel_MemoryMapCache(b *testing.B) {
+	c := NewMemoryMapCache(time.Millisecond)
+	defer c.Close()
+	benchmarkParallel(b, c)
+}
+
+func BenchmarkParallel_ShardedCache(b *testing.B) {
+	c := NewShardedCache(time.Millisecond)
+	defer c.Close()
+	benchmarkParallel(b, c)
+}
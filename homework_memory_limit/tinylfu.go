@@ -0,0 +1,222 @@
+package cache
+
+import "container/list"
+
+var _ EvictionPolicy = &TinyLFUPolicy{}
+
+const (
+	tinyLFUWindow = iota
+	tinyLFUProbation
+	tinyLFUProtected
+)
+
+// TinyLFUPolicy W-TinyLFU 淘汰策略
+// 新的 key 先进入窗口（LRU，占 1%），窗口满了之后，窗口里面最旧的 key 是候选者，
+// 需要淘汰的时候，候选者和主缓存最旧的 key 比较访问频率，频率低的被淘汰。
+// 主缓存是分段 LRU：试用区里面的 key 再次被访问之后进入保护区（占主缓存的 80%）。
+// 访问频率用 Count-Min Sketch 估计，定期减半，所以已经淘汰的 key 也有历史频率
+type TinyLFUPolicy struct {
+	windowCap    int
+	mainCap      int
+	protectedCap int
+
+	sketch  *cmSketch
+	lists   [3]*list.List
+	entries map[string]*tinyLFUEntry
+}
+
+type tinyLFUEntry struct {
+	key   string
+	hash  uint64
+	where int
+	ele   *list.Element
+}
+
+// NewTinyLFUPolicy size 是预计缓存的 key 的数量，用来划分窗口和主缓存，以及确定 Sketch 的大小
+// 实际数量超过 size 也可以正常工作，只是窗口会变大
+func NewTinyLFUPolicy(size int) *TinyLFUPolicy {
+	if size < 1 {
+		size = 1
+	}
+	windowCap := size / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	mainCap := size - windowCap
+	return &TinyLFUPolicy{
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * 8 / 10,
+		sketch:       newCMSketch(size),
+		lists:        [3]*list.List{list.New(), list.New(), list.New()},
+		entries:      make(map[string]*tinyLFUEntry, size),
+	}
+}
+
+func (t *TinyLFUPolicy) RecordAccess(key string) {
+	entry, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	t.sketch.increment(entry.hash)
+	switch entry.where {
+	case tinyLFUProbation:
+		t.move(entry, tinyLFUProtected)
+		// 保护区满了，把最旧的降级到试用区
+		if protected := t.lists[tinyLFUProtected]; protected.Len() > t.protectedCap {
+			t.move(protected.Back().Value.(*tinyLFUEntry), tinyLFUProbation)
+		}
+	default:
+		t.lists[entry.where].MoveToFront(entry.ele)
+	}
+}
+
+func (t *TinyLFUPolicy) RecordInsert(key string) {
+	if _, ok := t.entries[key]; ok {
+		t.RecordAccess(key)
+		return
+	}
+	entry := &tinyLFUEntry{key: key, hash: hashKey(key), where: tinyLFUWindow}
+	t.sketch.increment(entry.hash)
+	entry.ele = t.lists[tinyLFUWindow].PushFront(entry)
+	t.entries[key] = entry
+	// 窗口满了，主缓存还有空间的话直接放进去，不需要和别人比较
+	window := t.lists[tinyLFUWindow]
+	if window.Len() > t.windowCap && t.mainLen() < t.mainCap {
+		t.move(window.Back().Value.(*tinyLFUEntry), tinyLFUProbation)
+	}
+}
+
+func (t *TinyLFUPolicy) Victim() (string, bool) {
+	victim := t.mainVictim()
+	window := t.lists[tinyLFUWindow]
+	if ele := window.Back(); ele != nil {
+		candidate := ele.Value.(*tinyLFUEntry)
+		switch {
+		case victim == nil:
+			victim = candidate
+		case window.Len() > t.windowCap:
+			// 窗口超出了容量，候选者和主缓存最旧的 key 比较，频率高的留下
+			if t.sketch.estimate(candidate.hash) > t.sketch.estimate(victim.hash) {
+				t.move(candidate, tinyLFUProbation)
+			} else {
+				victim = candidate
+			}
+		}
+	}
+	if victim == nil {
+		return "", false
+	}
+	t.Remove(victim.key)
+	return victim.key, true
+}
+
+func (t *TinyLFUPolicy) Remove(key string) {
+	if entry, ok := t.entries[key]; ok {
+		t.lists[entry.where].Remove(entry.ele)
+		delete(t.entries, key)
+	}
+}
+
+// mainVictim 主缓存里面最旧的 key，优先从试用区淘汰
+func (t *TinyLFUPolicy) mainVictim() *tinyLFUEntry {
+	if ele := t.lists[tinyLFUProbation].Back(); ele != nil {
+		return ele.Value.(*tinyLFUEntry)
+	}
+	if ele := t.lists[tinyLFUProtected].Back(); ele != nil {
+		return ele.Value.(*tinyLFUEntry)
+	}
+	return nil
+}
+
+func (t *TinyLFUPolicy) mainLen() int {
+	return t.lists[tinyLFUProbation].Len() + t.lists[tinyLFUProtected].Len()
+}
+
+func (t *TinyLFUPolicy) move(entry *tinyLFUEntry, where int) {
+	t.lists[entry.where].Remove(entry.ele)
+	entry.where = where
+	entry.ele = t.lists[where].PushFront(entry)
+}
+
+const (
+	cmDepth = 4
+	// cmMaxCount 计数器最大值，和四位的计数器一样
+	cmMaxCount = 15
+)
+
+// cmSketch Count-Min Sketch，用固定的内存估计 key 的访问频率，
+// 估计值只会偏大不会偏小。累计次数达到 10 倍宽度之后所有计数器减半，让旧的热点逐渐冷却
+type cmSketch struct {
+	rows      [cmDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newCMSketch(size int) *cmSketch {
+	width := 16
+	for width < size {
+		width <<= 1
+	}
+	res := &cmSketch{
+		mask:    uint64(width - 1),
+		resetAt: width * 10,
+	}
+	for i := range res.rows {
+		res.rows[i] = make([]uint8, width)
+	}
+	return res
+}
+
+func (s *cmSketch) increment(hash uint64) {
+	for i := range s.rows {
+		idx := s.index(hash, i)
+		if s.rows[i][idx] < cmMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(hash uint64) uint8 {
+	res := uint8(cmMaxCount)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(hash, i)]; v < res {
+			res = v
+		}
+	}
+	return res
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// index 双重哈希，用一个哈希值模拟多个哈希函数
+func (s *cmSketch) index(hash uint64, i int) uint64 {
+	h2 := hash>>32 | 1
+	return (hash + uint64(i)*h2) & s.mask
+}
+
+// hashKey FNV-1a，不需要分配内存
+func hashKey(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return h
+}
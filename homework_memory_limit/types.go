@@ -26,8 +26,20 @@ type CacheV1 interface {
 	LoadAndDelete(ctx context.Context, key string) ([]byte, error)
 
 	Subscribe() <-chan Event
+	// Unsubscribe 取消订阅，ch 会被关闭
+	Unsubscribe(ch <-chan Event)
 }
 
+const (
+	EventSet = iota + 1
+	EventGet
+	EventDelete
+	// EventExpire 过期被删除
+	EventExpire
+	// EventEvict 没有过期，但是被淘汰了，例如超过了最大内存
+	EventEvict
+)
+
 type Event struct {
 	Key string
 	Val any
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type WriteBackCacheOption func(cache *WriteBackCache)
+
+// WriteBackCache 只写缓存，数据批量、定期地写回数据源
+// 写回之前数据只在内存里面，进程崩溃的时候会丢失，所以只适合能容忍丢数据的场景
+// 缓存淘汰不会影响写回，Delete 会取消还没有写回的数据，但是不会删除数据源里面的数据
+type WriteBackCache struct {
+	Cache
+	storeFunc func(ctx context.Context, vals map[string][]byte) error
+	interval  time.Duration
+	// batchSize 没有写回的数据达到这个数量，就立刻写回，为 0 说明只定期写回
+	batchSize  int
+	onStoreErr func(err error)
+
+	mutex sync.Mutex
+	dirty map[string][]byte
+	// deleted 写回期间被删除的 key，写回失败的时候不能放回去，没有在写回的时候是 nil
+	deleted map[string]struct{}
+	// flushMutex 保证同一时刻只有一个写回
+	flushMutex sync.Mutex
+	flushCh    chan struct{}
+	close      chan struct{}
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewWriteBackCache 创建 WriteBackCache，每 interval 调用一次 storeFunc 写回所有的脏数据
+// 用完之后需要调用 Close，保证剩下的数据都写回了
+func NewWriteBackCache(cache Cache, storeFunc func(ctx context.Context, vals map[string][]byte) error,
+	interval time.Duration, opts ...WriteBackCacheOption) *WriteBackCache {
+	res := &WriteBackCache{
+		Cache:     cache,
+		storeFunc: storeFunc,
+		interval:  interval,
+		onStoreErr: func(err error) {
+			log.Println("cache: 写回数据失败", err)
+		},
+		dirty:   make(map[string][]byte, 16),
+		flushCh: make(chan struct{}, 1),
+		close:   make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	go res.loop()
+	return res
+}
+
+// WriteBackWithBatchSize 脏数据达到 size 个就立刻写回
+func WriteBackWithBatchSize(size int) WriteBackCacheOption {
+	return func(cache *WriteBackCache) {
+		cache.batchSize = size
+	}
+}
+
+// WriteBackWithErrorHandler 写回失败的时候调用，默认是打印日志
+// 失败的数据会在下一次写回的时候重试
+func WriteBackWithErrorHandler(fn func(err error)) WriteBackCacheOption {
+	return func(cache *WriteBackCache) {
+		cache.onStoreErr = fn
+	}
+}
+
+func (w *WriteBackCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	if err := w.Cache.Set(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	w.dirty[key] = val
+	full := w.batchSize > 0 && len(w.dirty) >= w.batchSize
+	w.mutex.Unlock()
+	if full {
+		select {
+		case w.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (w *WriteBackCache) Delete(ctx context.Context, key string) error {
+	w.forget(key)
+	return w.Cache.Delete(ctx, key)
+}
+
+func (w *WriteBackCache) LoadAndDelete(ctx context.Context, key string) ([]byte, error) {
+	w.forget(key)
+	return w.Cache.LoadAndDelete(ctx, key)
+}
+
+// forget 取消还没有写回的数据
+func (w *WriteBackCache) forget(key string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delete(w.dirty, key)
+	if w.deleted != nil {
+		w.deleted[key] = struct{}{}
+	}
+}
+
+// Flush 立刻写回所有的脏数据
+func (w *WriteBackCache) Flush(ctx context.Context) error {
+	w.flushMutex.Lock()
+	defer w.flushMutex.Unlock()
+	w.mutex.Lock()
+	if len(w.dirty) == 0 {
+		w.mutex.Unlock()
+		return nil
+	}
+	vals := w.dirty
+	w.dirty = make(map[string][]byte, len(vals))
+	w.deleted = make(map[string]struct{})
+	w.mutex.Unlock()
+
+	err := w.storeFunc(ctx, vals)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err != nil {
+		// 放回去重试，写回期间又被修改过的 key 以新的值为准，被删除的 key 不再写回
+		for k, v := range vals {
+			if _, ok := w.deleted[k]; ok {
+				continue
+			}
+			if _, ok := w.dirty[k]; !ok {
+				w.dirty[k] = v
+			}
+		}
+	}
+	w.deleted = nil
+	return err
+}
+
+// Close 停止定期写回，并且把剩下的脏数据写回去
+func (w *WriteBackCache) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.close)
+	})
+	<-w.closed
+	return w.Flush(context.Background())
+}
+
+func (w *WriteBackCache) loop() {
+	defer close(w.closed)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flushCh:
+		case <-w.close:
+			return
+		}
+		if err := w.Flush(context.Background()); err != nil {
+			w.onStoreErr(err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore 记录写回的数据
+type mockStore struct {
+	mutex   sync.Mutex
+	data    map[string][]byte
+	batches int
+	err     error
+}
+
+func (m *mockStore) store(ctx context.Context, vals map[string][]byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.batches++
+	for k, v := range vals {
+		m.data[k] = v
+	}
+	return nil
+}
+
+func (m *mockStore) get(key string) ([]byte, int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.data[key], m.batches
+}
+
+func TestWriteBackCache_Interval(t *testing.T) {
+	store := &mockStore{data: map[string][]byte{}}
+	c := NewMemoryMapCache(time.Minute)
+	defer c.Close()
+	wc := NewWriteBackCache(c, store.store, 50*time.Millisecond)
+	ctx := context.Background()
+
+	require.NoError(t, wc.Set(ctx, "key1", []byte("v1"), time.Minute))
+	require.NoError(t, wc.Set(ctx, "key1", []byte("v2"), time.Minute))
+	require.NoError(t, wc.Set(ctx, "key2", []byte("v1"), time.Minute))
+	require.NoError(t, wc.Set(ctx, "key3", []byte("v1"), time.Minute))
+	// 还没有写回之前删掉的不会写回
+	require.NoError(t, wc.Delete(ctx, "key3"))
+	val, batches := store.get("key1")
+	assert.Nil(t, val)
+	assert.Equal(t, 0, batches)
+
+	require.Eventually(t, func() bool {
+		_, batches = store.get("key1")
+		return batches == 1
+	}, time.Second, time.Millisecond)
+	val, _ = store.get("key1")
+	assert.Equal(t, []byte("v2"), val)
+	val, _ = store.get("key2")
+	assert.Equal(t, []byte("v1"), val)
+	val, _ = store.get("key3")
+	assert.Nil(t, val)
+
+	// Close 会写回剩下的数据
+	require.NoError(t, wc.Set(ctx, "key4", []byte("v1"), time.Minute))
+	require.NoError(t, wc.Close())
+	val, _ = store.get("key4")
+	assert.Equal(t, []byte("v1"), val)
+}
+
+func TestWriteBackCache_BatchSize(t *testing.T) {
+	store := &mockStore{data: map[string][]byte{}}
+	c := NewMemoryMapCache(time.Minute)
+	defer c.Close()
+	wc := NewWriteBackCache(c, store.store, time.Hour, WriteBackWithBatchSize(2))
+	defer wc.Close()
+	ctx := context.Background()
+
+	require.NoError(t, wc.Set(ctx, "key1", []byte("v1"), time.Minute))
+	time.Sleep(20 * time.Millisecond)
+	_, batches := store.get("key1")
+	assert.Equal(t, 0, batches)
+	require.NoError(t, wc.Set(ctx, "key2", []byte("v1"), time.Minute))
+	require.Eventually(t, func() bool {
+		_, batches = store.get("key1")
+		return batches == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestWriteBackCache_Retry(t *testing.T) {
+	store := &mockStore{data: map[string][]byte{}, err: errors.New("mock error")}
+	c := NewMemoryMapCache(time.Minute)
+	defer c.Close()
+	wc := NewWriteBackCache(c, store.store, time.Hour, WriteBackWithErrorHandler(func(err error) {
+		t.Error("定期写回不应该执行", err)
+	}))
+	ctx := context.Background()
+
+	require.NoError(t, wc.Set(ctx, "key1", []byte("v1"), time.Minute))
+	assert.Equal(t, store.err, wc.Flush(ctx))
+	// 失败的数据放回去了，写回期间的新值优先
+	require.NoError(t, wc.Set(ctx, "key1", []byte("v2"), time.Minute))
+
+	store.mutex.Lock()
+	store.err = nil
+	store.mutex.Unlock()
+	require.NoError(t, wc.Close())
+	val, _ := store.get("key1")
+	assert.Equal(t, []byte("v2"), val)
+}
+
+// TestWriteBackCache_RetryDeleted 写回期间被删除的 key，写回失败之后不会再写回
+func TestWriteBackCache_RetryDeleted(t *testing.T) {
+	store := &mockStore{data: map[string][]byte{}}
+	c := NewMemoryMapCache(time.Minute)
+	defer c.Close()
+	var wc *WriteBackCache
+	fail := true
+	wc = NewWriteBackCache(c, func(ctx context.Context, vals map[string][]byte) error {
+		if fail {
+			fail = false
+			// 写回的同时删除 key1
+			require.NoError(t, wc.Delete(ctx, "key1"))
+			return errors.New("mock error")
+		}
+		return store.store(ctx, vals)
+	}, time.Hour, WriteBackWithErrorHandler(func(err error) {
+		t.Error("定期写回不应该执行", err)
+	}))
+	ctx := context.Background()
+
+	require.NoError(t, wc.Set(ctx, "key1", []byte("v1"), time.Minute))
+	require.NoError(t, wc.Set(ctx, "key2", []byte("v2"), time.Minute))
+	assert.EqualError(t, wc.Flush(ctx), "mock error")
+
+	require.NoError(t, wc.Close())
+	val, batches := store.get("key1")
+	assert.Nil(t, val)
+	assert.Equal(t, 1, batches)
+	val, _ = store.get("key2")
+	assert.Equal(t, []byte("v2"), val)
+}
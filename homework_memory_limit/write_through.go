@@ -0,0 +1,21 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// WriteThroughCache 先写数据源，成功之后再写缓存，保证缓存里面的数据已经持久化了
+// 使用的时候 Cache、StoreFunc 都必须赋值
+type WriteThroughCache struct {
+	Cache
+	// StoreFunc 把数据写到数据源，例如写数据库
+	StoreFunc func(ctx context.Context, key string, val []byte) error
+}
+
+func (w *WriteThroughCache) Set(ctx context.Context, key string, val []byte, expiration time.Duration) error {
+	if err := w.StoreFunc(ctx, key, val); err != nil {
+		return err
+	}
+	return w.Cache.Set(ctx, key, val, expiration)
+}
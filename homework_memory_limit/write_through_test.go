@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteThroughCache_Set(t *testing.T) {
+	testCases := []struct {
+		name     string
+		storeErr error
+		// wantCached 数据源写失败的时候不写缓存
+		wantCached bool
+	}{
+		{
+			name:       "success",
+			wantCached: true,
+		},
+		{
+			name:     "store error",
+			storeErr: errors.New("mock error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewMemoryMapCache(time.Minute)
+			defer c.Close()
+			var stored []byte
+			wc := &WriteThroughCache{
+				Cache: c,
+				StoreFunc: func(ctx context.Context, key string, val []byte) error {
+					stored = val
+					return tc.storeErr
+				},
+			}
+			err := wc.Set(context.Background(), "key1", []byte("val"), time.Minute)
+			assert.Equal(t, tc.storeErr, err)
+			assert.Equal(t, []byte("val"), stored)
+			_, err = c.Get(context.Background(), "key1")
+			assert.Equal(t, tc.wantCached, err == nil)
+		})
+	}
+}
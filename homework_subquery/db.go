@@ -30,6 +30,11 @@ func (db *DB) Wait() error {
 	return err
 }
 
+// PingContext 检查数据库连接是否可用，可以用于健康检查
+func (db *DB) PingContext(ctx context.Context) error {
+	return db.db.PingContext(ctx)
+}
+
 // Open 创建一个 DB 实例。
 // 默认情况下，该 DB 将使用 MySQL 作为方言
 // 如果你使用了其它数据库，可以使用 DBWithDialect 指定
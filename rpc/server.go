@@ -12,13 +12,30 @@ import (
 	"net"
 	"reflect"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ErrServerClosed Shutdown 之后 Start 返回的错误
+var ErrServerClosed = errors.New("micro: 服务器已关闭")
+
+var errServerRejecting = errors.New("micro: 服务器正在关闭，拒绝新请求")
+
 type Server struct {
+	// inFlight 正在处理的请求数量，放在最前面保证 64 位对齐
+	inFlight int64
+	// rejecting 不为 0 说明在关闭中，拒绝新请求
+	rejecting int32
+
 	services     map[string]reflectionStub
 	serializers  map[uint8]serialize.Serializer
 	compressions map[uint8]compression.Compression
+
+	mutex     sync.Mutex
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
 }
 
 func NewServer() *Server {
@@ -26,6 +43,7 @@ func NewServer() *Server {
 		services:     make(map[string]reflectionStub, 16),
 		serializers:  make(map[uint8]serialize.Serializer, 4),
 		compressions: make(map[uint8]compression.Compression, 4),
+		conns:        make(map[net.Conn]struct{}, 16),
 	}
 	res.RegisterSerializer(&json.Serializer{})
 	res.RegisterCompression(&zstd.Compressor{})
@@ -48,26 +66,146 @@ func (s *Server) RegisterService(service Service) {
 	}
 }
 
+// Start 启动服务器，会一直阻塞，Shutdown 之后返回 ErrServerClosed
 func (s *Server) Start(network, addr string) error {
 	listener, err := net.Listen(network, addr)
 	if err != nil {
 		// 比较常见的就是端口被占用
 		return err
 	}
+	return s.Serve(listener)
+}
+
+// Serve 在已有的 listener 上处理请求
+func (s *Server) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		_ = listener.Close()
+		return ErrServerClosed
+	}
+	s.listeners = append(s.listeners, listener)
+	s.mutex.Unlock()
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
 			return err
 		}
+		if !s.trackConn(conn) {
+			_ = conn.Close()
+			return ErrServerClosed
+		}
 		go func() {
 			if er := s.handleConn(conn); er != nil {
 				_ = conn.Close()
 			}
+			s.untrackConn(conn)
 		}()
 	}
 }
 
+// RejectNew 拒绝新请求，新请求会收到错误响应，已经在处理的请求不受影响
+func (s *Server) RejectNew() {
+	atomic.StoreInt32(&s.rejecting, 1)
+}
+
+// InFlight 正在处理的请求数量
+func (s *Server) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// Shutdown 优雅退出：拒绝新请求，关闭监听，等待正在处理的请求结束，最后关闭所有的连接
+// ctx 超时的时候也会关闭所有的连接，并且返回 ctx.Err()
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.RejectNew()
+	s.mutex.Lock()
+	s.closed = true
+	for _, l := range s.listeners {
+		_ = l.Close()
+	}
+	s.listeners = nil
+	s.mutex.Unlock()
+
+	var err error
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for err == nil && s.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	s.mutex.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mutex.Unlock()
+	return err
+}
+
+// Handoff 零停机重启的时候使用，关闭监听，新的连接都由子进程接收，不会拒绝请求
+// 已经接收的连接处理完当前的请求就断开，客户端重新连接的时候由子进程接收。
+// ctx 超时之后不再等待，之后再调用 Shutdown 关闭剩下的连接
+func (s *Server) Handoff(ctx context.Context) error {
+	s.mutex.Lock()
+	s.closed = true
+	for _, l := range s.listeners {
+		_ = l.Close()
+	}
+	s.listeners = nil
+	// 空闲的连接立刻返回，正在处理请求的连接写完响应之后，读下一个请求的时候返回
+	now := time.Now()
+	for conn := range s.conns {
+		_ = conn.SetReadDeadline(now)
+	}
+	s.mutex.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for s.InFlight() > 0 || s.connCount() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func (s *Server) connCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.conns)
+}
+
+func (s *Server) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mutex.Lock()
+	delete(s.conns, conn)
+	s.mutex.Unlock()
+}
+
 // 我们可以认为，一个请求包含两部分
 // 1. 长度字段：用八个字节表示
 // 2. 请求数据：
@@ -84,6 +222,15 @@ func (s *Server) handleConn(conn net.Conn) error {
 		if err != nil {
 			return err
 		}
+		// 先计数再检查，保证 Shutdown 看到计数为 0 的时候，不会再有请求开始执行
+		atomic.AddInt64(&s.inFlight, 1)
+		if atomic.LoadInt32(&s.rejecting) != 0 {
+			atomic.AddInt64(&s.inFlight, -1)
+			if err = s.reject(conn, req); err != nil {
+				return err
+			}
+			continue
+		}
 		ctx := context.Background()
 		cancel := func() {}
 		log.Println(req.Meta)
@@ -108,6 +255,7 @@ func (s *Server) handleConn(conn net.Conn) error {
 		resp.CalculateHeaderLength()
 		resp.CalculateBodyLength()
 		_, err = conn.Write(message.EncodeResp(resp))
+		atomic.AddInt64(&s.inFlight, -1)
 
 		if err != nil {
 			return err
@@ -115,6 +263,20 @@ func (s *Server) handleConn(conn net.Conn) error {
 	}
 }
 
+func (s *Server) reject(conn net.Conn, req *message.Request) error {
+	resp := &message.Response{
+		RequestID:  req.RequestID,
+		Version:    req.Version,
+		Compresser: req.Compresser,
+		Serializer: req.Serializer,
+		Error:      []byte(errServerRejecting.Error()),
+	}
+	resp.CalculateHeaderLength()
+	resp.CalculateBodyLength()
+	_, err := conn.Write(message.EncodeResp(resp))
+	return err
+}
+
 func (s *Server) Invoke(ctx context.Context, req *message.Request) (*message.Response, error) {
 	//if isOneway(ctx) {
 	//	go func() {
@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Shutdown(t *testing.T) {
+	server := NewServer()
+	service := &UserServiceServerTimeout{t: t, sleep: 200 * time.Millisecond, Msg: "hello, world"}
+	server.RegisterService(service)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(l)
+	}()
+
+	usClient := &UserService{}
+	client, err := NewClient(l.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, client.InitService(usClient))
+
+	type result struct {
+		resp *GetByIdResp
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		resp, er := usClient.GetById(ctx, &GetByIdReq{Id: 123})
+		resCh <- result{resp: resp, err: er}
+	}()
+	require.Eventually(t, func() bool {
+		return server.InFlight() == 1
+	}, time.Second, time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, server.Shutdown(context.Background()))
+	// 等到正在处理的请求结束才返回
+	assert.Greater(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, int64(0), server.InFlight())
+
+	res := <-resCh
+	require.NoError(t, res.err)
+	assert.Equal(t, "hello, world", res.resp.Msg)
+	assert.Equal(t, ErrServerClosed, <-serveErr)
+	assert.Equal(t, ErrServerClosed, server.Start("tcp", "127.0.0.1:0"))
+}
+
+func TestServer_Handoff(t *testing.T) {
+	server := NewServer()
+	service := &UserServiceServerTimeout{t: t, sleep: 200 * time.Millisecond, Msg: "hello, world"}
+	server.RegisterService(service)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(l)
+	}()
+
+	// 一个空闲的连接，交接的时候会被断开
+	idle, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer idle.Close()
+
+	usClient := &UserService{}
+	client, err := NewClient(addr)
+	require.NoError(t, err)
+	require.NoError(t, client.InitService(usClient))
+
+	type result struct {
+		resp *GetByIdResp
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		resp, er := usClient.GetById(ctx, &GetByIdReq{Id: 123})
+		resCh <- result{resp: resp, err: er}
+	}()
+	require.Eventually(t, func() bool {
+		return server.InFlight() == 1
+	}, time.Second, time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, server.Handoff(context.Background()))
+	// 等到正在处理的请求结束，并且所有的连接都断开才返回
+	assert.Greater(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, ErrServerClosed, <-serveErr)
+	_, err = net.DialTimeout("tcp", addr, 100*time.Millisecond)
+	assert.Error(t, err)
+
+	// 正在处理的请求正常返回，没有被拒绝
+	res := <-resCh
+	require.NoError(t, res.err)
+	assert.Equal(t, "hello, world", res.resp.Msg)
+
+	require.NoError(t, idle.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = idle.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	require.NoError(t, server.Shutdown(context.Background()))
+}
+
+func TestServer_Shutdown_Timeout(t *testing.T) {
+	server := NewServer()
+	service := &UserServiceServerTimeout{t: t, sleep: time.Second}
+	server.RegisterService(service)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = server.Serve(l)
+	}()
+
+	usClient := &UserService{}
+	client, err := NewClient(l.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, client.InitService(usClient))
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_, _ = usClient.GetById(ctx, &GetByIdReq{Id: 123})
+	}()
+	require.Eventually(t, func() bool {
+		return server.InFlight() == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, server.Shutdown(ctx))
+}